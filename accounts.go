@@ -0,0 +1,349 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/shopspring/decimal"
+)
+
+const ACCOUNTS_COMMAND = "accounts"
+
+// name of the credentials read from GATEIO_KEY and GATEIO_SECRET
+const DEFAULT_ACCOUNT = "default"
+
+// -split-accounts value selecting every configured account
+const ALL_ACCOUNTS = "all"
+
+const (
+	GATEIO_KEY_ENV    = "GATEIO_KEY"
+	GATEIO_SECRET_ENV = "GATEIO_SECRET"
+)
+
+var splitAccounts string
+
+// environment variables holding the api keys of an account, GATEIO_KEY_<ACCOUNT> and GATEIO_SECRET_<ACCOUNT>
+func credentialsEnv(account string) (string, string) {
+	account = accountLabel(account)
+	if account == DEFAULT_ACCOUNT {
+		return GATEIO_KEY_ENV, GATEIO_SECRET_ENV
+	}
+	suffix := "_" + strings.ToUpper(strings.ReplaceAll(account, "-", "_"))
+	return GATEIO_KEY_ENV + suffix, GATEIO_SECRET_ENV + suffix
+}
+
+func envCredentials(account string) (string, string) {
+	keyName, secretName := credentialsEnv(account)
+	return os.Getenv(keyName), os.Getenv(secretName)
+}
+
+// api keys of an account, from -key-cmd, -key-fd, the environment then the keystore
+func accountCredentials(account string) (string, string, error) {
+	if keyCommand != "" {
+		return keysFromCommand(account)
+	}
+	if keyFd > 0 {
+		return keysFromFd()
+	}
+	if key, secret := envCredentials(account); key != "" && secret != "" {
+		return key, secret, nil
+	}
+
+	key, secret, found, err := keysFromKeystore(account)
+	if found {
+		return key, secret, err
+	}
+	if err != nil {
+		return "", "", err
+	}
+	keyName, secretName := credentialsEnv(account)
+	return "", "", validationErrorf("api keys of account %s are missing, set %s and %s or store them with: steps keys add %s", accountLabel(account), keyName, secretName, accountLabel(account))
+}
+
+// account names are case insensitive, this is the one form used for lookups and storage
+func accountLabel(account string) string {
+	account = strings.ToLower(strings.TrimSpace(account))
+	if account == "" {
+		return DEFAULT_ACCOUNT
+	}
+	return account
+}
+
+// accounts with both keys in the environment or in the keystore, the default one first
+func configuredAccounts() []string {
+	found := map[string]bool{}
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		if value == "" || !strings.HasPrefix(name, GATEIO_KEY_ENV+"_") {
+			continue
+		}
+		account := accountLabel(strings.TrimPrefix(name, GATEIO_KEY_ENV+"_"))
+		if key, secret := envCredentials(account); key != "" && secret != "" {
+			found[account] = true
+		}
+	}
+	for _, account := range keystoreAccounts() {
+		found[account] = true
+	}
+	if key, secret := envCredentials(DEFAULT_ACCOUNT); key != "" && secret != "" {
+		found[DEFAULT_ACCOUNT] = true
+	}
+
+	var accounts []string
+	for account := range found {
+		if account != DEFAULT_ACCOUNT {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Strings(accounts)
+	if found[DEFAULT_ACCOUNT] {
+		accounts = append([]string{DEFAULT_ACCOUNT}, accounts...)
+	}
+	return accounts
+}
+
+// accounts given to -split-accounts, without duplicates
+func splitAccountNames() []string {
+	if splitAccounts == ALL_ACCOUNTS {
+		return configuredAccounts()
+	}
+
+	var accounts []string
+	seen := map[string]bool{}
+	for _, field := range strings.Split(splitAccounts, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		account := accountLabel(field)
+		if !seen[account] {
+			seen[account] = true
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts
+}
+
+// print the accounts whose keys are configured and where they are read from, never the keys
+func printAccounts() {
+	accounts := configuredAccounts()
+	if len(accounts) == 0 {
+		fmt.Printf("No account configured, set %s and %s or %s_<ACCOUNT> and %s_<ACCOUNT>, or use steps keys add\n", GATEIO_KEY_ENV, GATEIO_SECRET_ENV, GATEIO_KEY_ENV, GATEIO_SECRET_ENV)
+		return
+	}
+	for _, account := range accounts {
+		if key, secret := envCredentials(account); key != "" && secret != "" {
+			keyName, secretName := credentialsEnv(account)
+			fmt.Printf("%s\t%s, %s\n", account, keyName, secretName)
+		} else {
+			fmt.Printf("%s\tkeystore\n", account)
+		}
+	}
+}
+
+// exchange of one account, every simulated account starts from the -simbalance balances
+func accountExchange(account string) (Exchange, error) {
+	if exchangeName == SIMULATOR_EXCHANGE {
+		return newSimulatorFromFlags(), nil
+	}
+	key, secret, err := accountCredentials(account)
+	if err != nil {
+		return nil, err
+	}
+	return newRetryExchange(newGateioExchange(key, secret)), nil
+}
+
+// accountLadder is the part of a split ladder placed by one account
+type accountLadder struct {
+	Account  string
+	Exchange Exchange
+	Journal  *journalExchange
+	Balance  float64
+	Amount   float64
+	Plan     Plan
+}
+
+// split amount proportionally to the positive balances, the shares add up exactly to amount
+func splitByBalance(amount float64, balances []float64) []float64 {
+	total := decimal.Zero
+	for _, balance := range balances {
+		if balance > 0 {
+			total = total.Add(decimal.NewFromFloat(balance))
+		}
+	}
+
+	shares := make([]float64, len(balances))
+	if !total.IsPositive() {
+		return shares
+	}
+
+	left := decimal.NewFromFloat(amount)
+	last := -1
+	for i, balance := range balances {
+		if balance > 0 {
+			last = i
+		}
+	}
+	for i, balance := range balances {
+		if balance <= 0 {
+			continue
+		}
+		share := left
+		if i != last {
+			share = decimal.NewFromFloat(amount).Mul(decimal.NewFromFloat(balance)).DivRound(total, LADDER_DIVISION_PRECISION).RoundFloor(8)
+		}
+		left = left.Sub(share)
+		shares[i], _ = share.Float64()
+	}
+	return shares
+}
+
+// place one ladder split over several accounts, each one takes a part proportional to its balance
+func runSplitLadder() {
+	var ladders []*accountLadder
+	for _, account := range splitAccountNames() {
+		exchange, err := accountExchange(account)
+		exitOnError(err)
+		journal := newJournalExchange(exchange)
+		ladders = append(ladders, &accountLadder{Account: account, Exchange: journal, Journal: journal})
+	}
+	if len(ladders) < 2 {
+		exitOnError(validationErrorf("a split ladder needs at least two accounts, %d configured", len(ladders)))
+	}
+
+	// market data is the same for every account
+	rules, err := getPairRules(ladders[0].Exchange, pair)
+	exitOnError(err)
+	currentPrice, err := getTickerPrice(ladders[0].Exchange, pair)
+	exitOnError(err)
+	if exchangeName == GATEIO_EXCHANGE {
+		if err := saveMarketCache(pair, currentPrice, rules); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot cache market data: %s\n", err)
+		}
+	}
+
+	for _, ladder := range ladders {
+		checkExistingOrders(ladder.Exchange, " on account "+ladder.Account)
+	}
+
+	// the amount is shared in proportion to the balance of the currency the orders spend
+	ticker, amount := ladderAmount()
+	spent := spentCurrency(pair, side)
+	var balances []float64
+	totalBalance := 0.0
+	for _, ladder := range ladders {
+		_, balance, err := balanceEnough(ladder.Exchange, spent, 0)
+		exitOnError(err)
+		ladder.Balance = balance
+		balances = append(balances, balance)
+		if balance > 0 {
+			totalBalance += balance
+		}
+	}
+	if totalBalance <= 0 {
+		fmt.Fprintf(os.Stderr, "\nNo %s on the accounts\n", spent)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Split of %s %s over %d accounts, proportional to their %s balance\n", formatAmount(amount, 8), ticker, len(ladders), spent)
+	for i, share := range splitByBalance(amount, balances) {
+		ladders[i].Amount = share
+		percent := 0.0
+		if ladders[i].Balance > 0 {
+			percent = ladders[i].Balance / totalBalance * 100
+		}
+		fmt.Printf("%s: balance %.4f %s, %.2f %%, amount %s %s\n", ladders[i].Account, ladders[i].Balance, spent, percent, formatAmount(share, 8), ticker)
+	}
+
+	var placed []*accountLadder
+	for _, ladder := range ladders {
+		if ladder.Amount <= 0 {
+			continue
+		}
+		ladder.Plan, err = planLadder(pair, side, priceMin, priceMax, ladder.Amount, ticker, ladderSpacing, rules, ladderDistribution)
+		if isValidationError(err) {
+			err = validationErrorf("account %s: %s", ladder.Account, err)
+		}
+		exitOnError(err)
+		checkPlanBalance(ladder.Exchange, ladder.Plan, " on account "+ladder.Account)
+		placed = append(placed, ladder)
+	}
+
+	fmt.Printf("\nHere are the orders you gonna create\n")
+	useTriggeredOrder := useSl
+	if !useTriggeredOrder {
+		fmt.Printf("Using limit orders\n")
+		var allOrders []gateapi.Order
+		for _, ladder := range placed {
+			fmt.Printf("\nAccount %s\n", ladder.Account)
+			ladder.Plan.print(LIMIT_ORDER)
+			allOrders = append(allOrders, ladder.Plan.limitOrders(timeInForce)...)
+		}
+
+		// the accounts share the same book, their levels are matched together
+		report, err := checkRisk(ladders[0].Exchange, pair, allOrders)
+		exitOnError(err)
+		printRiskReport(report, side, rules)
+
+		if len(report.crossing()) > 0 && chooseOnCross(report, currentPrice) {
+			useTriggeredOrder = true
+		}
+	}
+
+	if useTriggeredOrder {
+		fmt.Printf("Using Stop-limit orders\n")
+		var allSLOrders []gateapi.SpotPriceTriggeredOrder
+		for _, ladder := range placed {
+			fmt.Printf("\nAccount %s\n", ladder.Account)
+			exitOnError(ladder.Plan.checkTriggers())
+			ladder.Plan.print(STOP_LIMIT_ORDER)
+			allSLOrders = append(allSLOrders, ladder.Plan.stopLimitOrders()...)
+		}
+
+		report, err := checkStopLimitRisk(ladders[0].Exchange, pair, allSLOrders, currentPrice)
+		exitOnError(err)
+		printRiskReport(report, side, rules)
+		exitOnError(checkTakerExposure(report))
+	}
+
+	fmt.Println()
+	if !confirm("Do you want to continue?") {
+		os.Exit(0)
+	}
+
+	failed := false
+	for _, ladder := range placed {
+		fmt.Printf("\nAccount %s, ", ladder.Account)
+
+		var orders []gateapi.Order
+		var sLOrders []gateapi.SpotPriceTriggeredOrder
+		if useTriggeredOrder {
+			sLOrders = ladder.Plan.stopLimitOrders()
+		} else {
+			orders = ladder.Plan.limitOrders(timeInForce)
+		}
+
+		ok := placeLadder(ladder.Exchange, ladder.Journal, ladderRecord{
+			Exchange:       exchangeName,
+			Account:        ladder.Account,
+			Pair:           pair,
+			Side:           side,
+			PriceMin:       priceMin,
+			PriceMax:       priceMax,
+			Amount:         ladder.Amount,
+			AmountCurrency: ticker,
+			Spacing:        ladderSpacing,
+			Distribution:   ladderDistribution,
+			TimeInForce:    timeInForce,
+			StopLimit:      useTriggeredOrder,
+		}, orders, sLOrders)
+		if !ok {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
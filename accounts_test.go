@@ -0,0 +1,28 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitByBalance(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		balances []float64
+		want     []float64
+	}{
+		{"proportional", 300, []float64{100, 200}, []float64{100, 200}},
+		{"last share takes the rounding", 100, []float64{1, 1, 1}, []float64{33.33333333, 33.33333333, 33.33333334}},
+		{"non positive balances get nothing and weigh nothing", 150, []float64{100, 100, -50}, []float64{75, 75, 0}},
+		{"empty balance in the middle", 90, []float64{30, 0, 60}, []float64{30, 0, 60}},
+		{"no balance", 100, []float64{0, -1}, []float64{0, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := splitByBalance(test.amount, test.balances); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("splitByBalance(%v, %v) = %v, want %v", test.amount, test.balances, got, test.want)
+			}
+		})
+	}
+}
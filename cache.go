@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const MARKET_CACHE_FILE = "market.json"
+
+// last known market data of a pair, saved on every authenticated run
+type marketCacheEntry struct {
+	Price     float64   `json:"price"`
+	Rules     pairRules `json:"rules"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func marketCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "steps-bot", MARKET_CACHE_FILE), nil
+}
+
+func loadMarketCache() map[string]marketCacheEntry {
+	cache := map[string]marketCacheEntry{}
+
+	path, err := marketCachePath()
+	if err != nil {
+		return cache
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	// a corrupted cache is ignored, it is rewritten on the next run
+	_ = json.Unmarshal(content, &cache)
+	return cache
+}
+
+func saveMarketCache(pair string, price float64, rules pairRules) error {
+	path, err := marketCachePath()
+	if err != nil {
+		return err
+	}
+
+	cache := loadMarketCache()
+	cache[pair] = marketCacheEntry{Price: price, Rules: rules, UpdatedAt: time.Now()}
+
+	content, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, content)
+}
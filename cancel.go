@@ -0,0 +1,141 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const CANCEL_COMMAND = "cancel"
+
+const GATE_MAX_SIZE_CANCEL_BATCH int = 20
+
+var cancelTag string
+
+func checkCancelArgs() {
+	error := false
+
+	if side != "" && side != buy && side != sell {
+		fmt.Fprintf(os.Stderr, "Side accepted value. buy or sell\n")
+		error = true
+	}
+
+	if priceMin < 0.0 || priceMax < 0.0 {
+		fmt.Fprintf(os.Stderr, "min and max must be positive\n")
+		error = true
+	}
+
+	if priceMin > 0.0 && priceMax > 0.0 && priceMin > priceMax {
+		fmt.Fprintf(os.Stderr, "min cannot be higher than max\n")
+		error = true
+	}
+
+	if error {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// open orders of the pair matching the side, price range and tag filters, unset filters match everything
+func filterOrdersToCancel(orders []gateapi.Order) []gateapi.Order {
+	var selected []gateapi.Order
+	for _, order := range orders {
+		if side != "" && order.Side != side {
+			continue
+		}
+
+		price, _ := strconv.ParseFloat(order.Price, 64)
+		if priceMin > 0.0 && price < priceMin {
+			continue
+		}
+		if priceMax > 0.0 && price > priceMax {
+			continue
+		}
+
+		if cancelTag != "" && !strings.HasPrefix(order.Text, cancelTag) {
+			continue
+		}
+
+		selected = append(selected, order)
+	}
+	return selected
+}
+
+// open stop-limit orders matching the side and price range filters, they carry no text so a tag filter matches none
+func filterTriggeredOrdersToCancel(orders []gateapi.SpotPriceTriggeredOrder) []gateapi.SpotPriceTriggeredOrder {
+	if cancelTag != "" {
+		return nil
+	}
+
+	var selected []gateapi.SpotPriceTriggeredOrder
+	for _, order := range orders {
+		if side != "" && order.Put.Side != side {
+			continue
+		}
+
+		price, _ := strconv.ParseFloat(order.Put.Price, 64)
+		if priceMin > 0.0 && price < priceMin {
+			continue
+		}
+		if priceMax > 0.0 && price > priceMax {
+			continue
+		}
+
+		selected = append(selected, order)
+	}
+	return selected
+}
+
+func runCancel(exchange Exchange) {
+	openOrders, err := getOpenOrders(exchange, pair)
+	exitOnError(err)
+	orders := filterOrdersToCancel(filterLadderOrders(openOrders))
+	openTriggered, err := getTriggeredOrders(exchange, pair, "open")
+	exitOnError(err)
+	openTriggered, err = filterLadderTriggeredOrders(openTriggered)
+	exitOnError(err)
+	triggered := filterTriggeredOrdersToCancel(openTriggered)
+	if len(orders) == 0 && len(triggered) == 0 {
+		fmt.Printf("No open order to cancel on %s\n", pair)
+		return
+	}
+
+	_, quote, _ := splitPair(pair)
+	fmt.Printf("Orders to cancel on %s\n", pair)
+	for i := 0; i < len(orders); i++ {
+		fmt.Printf("%s ", orders[i].Side)
+		exitOnError(formatOpenOrders(&orders[i]))
+	}
+	for _, order := range triggered {
+		fmt.Printf("stop-limit %s when price %s %s %s: price: %s %s, amount: %s\n", order.Put.Side, order.Trigger.Rule, order.Trigger.Price, quote, order.Put.Price, quote, order.Put.Amount)
+	}
+
+	fmt.Println()
+	if !confirm(fmt.Sprintf("Cancel %d orders?", len(orders)+len(triggered))) {
+		return
+	}
+	fmt.Println()
+
+	cancelled := 0
+	for i := 0; i < len(orders); i += GATE_MAX_SIZE_CANCEL_BATCH {
+		end := i + GATE_MAX_SIZE_CANCEL_BATCH
+		if end > len(orders) {
+			end = len(orders)
+		}
+
+		count, err := cancelBatchOrders(exchange, orders[i:end])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Batch not cancelled: %s\n", err)
+		}
+		cancelled += count
+	}
+	cancelled += cancelTriggeredOrders(exchange, triggered)
+	fmt.Printf("%d/%d orders cancelled\n", cancelled, len(orders)+len(triggered))
+	if cancelled < len(orders)+len(triggered) {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const CONFIG_FILE = "config.yaml"
+
+// prefix of the environment variables overriding the configuration and the flags
+const ENV_PREFIX = "STEPS_"
+
+// flags a profile or a preset can set
+var CONFIG_KEYS = []string{
+	"pair", "account", "side", "timeinforce", "sl", "max-taker",
+	"steps", "stepspercent", "orders", "geometric",
+	"distribution", "ratio", "weights",
+	"amountQuote", "amountBase",
+}
+
+// flags that exclude each other, setting one on the command line discards the others from the configuration
+var CONFIG_EXCLUSIVE_KEYS = [][]string{
+	{"steps", "stepspercent", "orders"},
+	{"amountQuote", "amountBase"},
+}
+
+// flags kept as aliases, set the same value as their canonical flag
+var FLAG_ALIASES = map[string]string{
+	"amountUsdt": "amountQuote",
+	"amountAlph": "amountBase",
+}
+
+var configPath string
+var profileName string
+var presetName string
+
+// configFile holds named profiles, one per market or account, and named strategy presets.
+// Values are flag values, a preset is applied over the profile.
+type configFile struct {
+	// profile used when -profile is not given
+	Profile  string                       `yaml:"profile"`
+	Profiles map[string]map[string]string `yaml:"profiles"`
+	Presets  map[string]map[string]string `yaml:"presets"`
+}
+
+// directory of the configuration, $XDG_CONFIG_HOME/steps-bot or ~/.config/steps-bot
+func configDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "steps-bot"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "steps-bot"), nil
+}
+
+// first configuration file found in the user directory then in $XDG_CONFIG_DIRS, "" if none
+func findConfigFile() string {
+	var dirs []string
+	if dir, err := configDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	systemDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if systemDirs == "" {
+		systemDirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(systemDirs) {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "steps-bot"))
+		}
+	}
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, CONFIG_FILE)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+func loadConfig(path string) (configFile, error) {
+	var config configFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, validationErrorf("cannot read the configuration %s: %s", path, err)
+	}
+	return config, nil
+}
+
+// load .env from the current directory then from the configuration directory,
+// variables already set are kept so the environment wins over both files
+func loadDotEnv() {
+	paths := []string{".env"}
+	if dir, err := configDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, ".env"))
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if err := godotenv.Load(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot load %s: %s\n", path, err)
+		}
+	}
+}
+
+// environment variable overriding a flag, e.g. STEPS_PAIR or STEPS_MAX_TAKER
+func envName(flagName string) string {
+	return ENV_PREFIX + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func isConfigKey(name string) bool {
+	for _, key := range CONFIG_KEYS {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+func canonicalFlag(name string) string {
+	if canonical, ok := FLAG_ALIASES[name]; ok {
+		return canonical
+	}
+	return name
+}
+
+// flags excluding name, name included
+func exclusiveKeys(name string) []string {
+	name = canonicalFlag(name)
+	for _, keys := range CONFIG_EXCLUSIVE_KEYS {
+		for _, key := range keys {
+			if key == name {
+				return keys
+			}
+		}
+	}
+	return []string{name}
+}
+
+// settings of the selected profile with the selected preset over it
+func (c configFile) settings(profile string, preset string) (map[string]string, error) {
+	settings := map[string]string{}
+	layers := []struct {
+		kind   string
+		name   string
+		values map[string]map[string]string
+	}{
+		{"profile", profile, c.Profiles},
+		{"preset", preset, c.Presets},
+	}
+
+	for _, layer := range layers {
+		if layer.name == "" {
+			continue
+		}
+		values, ok := layer.values[layer.name]
+		if !ok {
+			return nil, validationErrorf("unknown %s %s", layer.kind, layer.name)
+		}
+
+		var keys []string
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !isConfigKey(key) {
+				return nil, validationErrorf("%s %s: unknown setting %s, accepted: %s", layer.kind, layer.name, key, strings.Join(CONFIG_KEYS, ", "))
+			}
+			// the preset spacing replaces the profile spacing, whichever flag each uses
+			for _, excluded := range exclusiveKeys(key) {
+				delete(settings, excluded)
+			}
+		}
+		for _, key := range keys {
+			settings[key] = values[key]
+		}
+	}
+	return settings, nil
+}
+
+// apply the configuration then the environment to the flags:
+// flags override the configuration and the environment overrides both
+func applyConfig() error {
+	setOnCommandLine := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		for _, key := range exclusiveKeys(f.Name) {
+			setOnCommandLine[key] = true
+		}
+	})
+
+	for _, name := range []string{"config", "profile", "preset"} {
+		if value, ok := os.LookupEnv(envName(name)); ok {
+			if err := flag.Set(name, value); err != nil {
+				return validationErrorf("%s: %s", envName(name), err)
+			}
+		}
+	}
+
+	path := configPath
+	if path == "" {
+		path = findConfigFile()
+	}
+	settings := map[string]string{}
+	if path != "" {
+		config, err := loadConfig(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return validationErrorf("configuration %s not found", path)
+		}
+		if err != nil {
+			return err
+		}
+
+		profile := profileName
+		if profile == "" {
+			profile = config.Profile
+		}
+		settings, err = config.settings(profile, presetName)
+		if err != nil {
+			return err
+		}
+		for key := range settings {
+			if setOnCommandLine[key] {
+				delete(settings, key)
+			}
+		}
+	} else if profileName != "" || presetName != "" {
+		return validationErrorf("no configuration file found, expected %s in the steps-bot configuration directory", CONFIG_FILE)
+	}
+
+	for _, key := range CONFIG_KEYS {
+		if value, ok := os.LookupEnv(envName(key)); ok {
+			for _, excluded := range exclusiveKeys(key) {
+				delete(settings, excluded)
+			}
+			settings[key] = value
+		}
+	}
+
+	var keys []string
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := flag.Set(key, settings[key]); err != nil {
+			return validationErrorf("invalid %s %q: %s", key, settings[key], err)
+		}
+	}
+	return nil
+}
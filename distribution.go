@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	FLAT_DISTRIBUTION        = "flat"
+	LINEAR_DISTRIBUTION      = "linear"
+	GEOMETRIC_DISTRIBUTION   = "geometric"
+	EXPONENTIAL_DISTRIBUTION = "exponential"
+	CUSTOM_DISTRIBUTION      = "custom"
+)
+
+const DEFAULT_DISTRIBUTION_RATIO float64 = 2
+
+// how the amount is spread over the ladder levels
+//
+//	flat:        same amount on every level
+//	linear:      the heaviest level gets Ratio times the lightest, linear in between
+//	exponential: the heaviest level gets Ratio times the lightest, exponential in between
+//	geometric:   every level gets Ratio times the previous one
+//	custom:      Weights, one per level from the lowest to the highest price
+//
+// Except for custom, the size grows away from the market:
+// towards the bottom of a buy ladder and towards the top of a sell ladder.
+type distribution struct {
+	Kind    string    `json:"kind"`
+	Ratio   float64   `json:"ratio,omitempty"`
+	Weights []float64 `json:"weights,omitempty"`
+}
+
+func parseWeights(value string) ([]float64, error) {
+	var weights []float64
+	for _, field := range strings.Split(value, ",") {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight %q", field)
+		}
+		if weight <= 0 {
+			return nil, fmt.Errorf("weights must be positive, got %s", field)
+		}
+		weights = append(weights, weight)
+	}
+	return weights, nil
+}
+
+func isDistribution(kind string) bool {
+	switch kind {
+	case FLAT_DISTRIBUTION, LINEAR_DISTRIBUTION, GEOMETRIC_DISTRIBUTION, EXPONENTIAL_DISTRIBUTION, CUSTOM_DISTRIBUTION:
+		return true
+	}
+	return false
+}
+
+// one weight per level ordered by ascending price, normalized so the mean weight is 1
+func ladderWeights(dist distribution, side string, levels int) ([]float64, error) {
+	if levels <= 0 {
+		return []float64{}, nil
+	}
+
+	weights := make([]float64, levels)
+	for i := range weights {
+		// position of the level between 0 (lowest price) and 1 (highest price)
+		x := 0.0
+		if levels > 1 {
+			x = float64(i) / float64(levels-1)
+		}
+
+		switch dist.Kind {
+		case FLAT_DISTRIBUTION, "":
+			weights[i] = 1
+		case LINEAR_DISTRIBUTION:
+			weights[i] = 1 + (dist.Ratio-1)*x
+		case EXPONENTIAL_DISTRIBUTION:
+			weights[i] = math.Pow(dist.Ratio, x)
+		case GEOMETRIC_DISTRIBUTION:
+			// relative to the heaviest level, in log space, so a long ladder cannot overflow
+			heaviest := levels - 1
+			if dist.Ratio < 1 {
+				heaviest = 0
+			}
+			weights[i] = math.Exp(float64(i-heaviest) * math.Log(dist.Ratio))
+		case CUSTOM_DISTRIBUTION:
+			if len(dist.Weights) != levels {
+				return nil, validationErrorf("%d weights given for %d levels", len(dist.Weights), levels)
+			}
+			weights[i] = dist.Weights[i]
+		default:
+			return nil, validationErrorf("unknown distribution %s", dist.Kind)
+		}
+	}
+
+	// heavier at the bottom of a buy ladder
+	if side == buy && dist.Kind != CUSTOM_DISTRIBUTION {
+		for i, j := 0, len(weights)-1; i < j; i, j = i+1, j-1 {
+			weights[i], weights[j] = weights[j], weights[i]
+		}
+	}
+
+	sum := 0.0
+	for _, weight := range weights {
+		sum += weight
+	}
+	for i := range weights {
+		weights[i] = weights[i] * float64(levels) / sum
+		if math.IsNaN(weights[i]) || math.IsInf(weights[i], 0) {
+			return nil, validationErrorf("the %s distribution gives no usable weight for level %d of %d, reduce the ratio or the number of levels", dist.Kind, i+1, levels)
+		}
+	}
+
+	return weights, nil
+}
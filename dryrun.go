@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// used when the pair was never cached and the public api is unreachable
+var DEFAULT_PAIR_RULES = pairRules{PricePrecision: 4, AmountPrecision: 2, MinQuoteAmount: 1}
+
+// print the ladder that would be sent, without api keys nor confirmation
+func runDryRun() {
+	cache := loadMarketCache()
+	cached, isCached := cache[pair]
+
+	// public endpoints do not need a key
+	public := newGateioExchange("", "")
+
+	rules := cached.Rules
+	if !isCached {
+		result, err := public.GetCurrencyPair(pair)
+		if err == nil {
+			rules = pairRulesFrom(result)
+		} else {
+			fmt.Fprintf(os.Stderr, "Pair rules unavailable (%s), using default precision\n", err)
+			rules = DEFAULT_PAIR_RULES
+		}
+	}
+
+	currentPrice := dryRunPrice
+	source := "given"
+	if currentPrice <= 0 && isCached {
+		currentPrice = cached.Price
+		source = "cached at " + cached.UpdatedAt.Format(time.RFC3339)
+	}
+	if currentPrice <= 0 {
+		result, err := public.ListTickers(pair)
+		if err == nil && len(result) > 0 {
+			currentPrice, _ = strconv.ParseFloat(result[0].Last, 64)
+			source = "live"
+		}
+	}
+	if currentPrice <= 0 {
+		fmt.Fprintf(os.Stderr, "No price available for %s, set one with -price\n", pair)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(humanOutput, "Dry run on %s, reference price %s %s (%s)\n", pair, formatAmount(currentPrice, rules.PricePrecision), quoteCurrency, source)
+	fmt.Fprintln(humanOutput)
+
+	ticker, amount := ladderAmount()
+
+	plan, err := planLadder(pair, side, priceMin, priceMax, amount, ticker, ladderSpacing, rules, ladderDistribution)
+	exitOnError(err)
+
+	orderType := LIMIT_ORDER
+	if useSl {
+		orderType = STOP_LIMIT_ORDER
+		exitOnError(plan.checkTriggers())
+		fmt.Fprintf(humanOutput, "Using Stop-limit orders\n")
+	} else {
+		fmt.Fprintf(humanOutput, "Using limit orders\n")
+	}
+	plan.print(orderType)
+	plan.printSummary()
+
+	var report riskReport
+	if useSl {
+		report, err = checkStopLimitRisk(public, pair, plan.stopLimitOrders(), currentPrice)
+	} else {
+		report, err = checkRisk(public, pair, plan.limitOrders(timeInForce))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Order book unavailable (%s), immediate fills not estimated\n", err)
+		warnReferenceCrossing(plan, orderType, currentPrice)
+	} else {
+		printRiskReport(report, side, rules)
+		if err := checkTakerExposure(report); err != nil {
+			fmt.Fprintf(humanOutput, "Warning: %s\n", err)
+		}
+	}
+
+	if outputFormat != TABLE_OUTPUT {
+		exitOnError(writeTable(plan.table(orderType)))
+	}
+}
+
+// without the book, the levels are only compared to the reference price: the limit levels
+// at or through it, and the stop-limit levels whose trigger it already reached
+func warnReferenceCrossing(plan Plan, orderType string, price float64) {
+	var prices []string
+	for _, level := range plan.Levels {
+		crosses := false
+		if orderType == STOP_LIMIT_ORDER {
+			trigger, _ := level.Trigger.Float64()
+			crosses = (plan.Side == buy && price >= trigger) || (plan.Side == sell && price <= trigger)
+		} else {
+			levelPrice, _ := level.Price.Float64()
+			crosses = (plan.Side == buy && levelPrice >= price) || (plan.Side == sell && levelPrice <= price)
+		}
+		if crosses {
+			prices = append(prices, level.price(plan.Rules))
+		}
+	}
+	if len(prices) > 0 {
+		fmt.Fprintf(humanOutput, "Warning: %d/%d %s levels would reach the book at once at the reference price: %s\n", len(prices), len(plan.Levels), plan.Side, strings.Join(prices, ", "))
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+// apiError is an exchange request that failed, rejected by the exchange or never answered
+type apiError struct {
+	Operation string
+	Err       error
+}
+
+func (e *apiError) Error() string {
+	if gateErr, ok := e.Err.(gateapi.GateAPIError); ok {
+		return fmt.Sprintf("%s: gate api error: %s", e.Operation, gateErr.Error())
+	}
+	return fmt.Sprintf("%s: generic error: %s", e.Operation, e.Err.Error())
+}
+
+func (e *apiError) Unwrap() error {
+	return e.Err
+}
+
+// Label is the Gate.io error label, empty when the exchange did not answer
+func (e *apiError) Label() string {
+	if gateErr, ok := e.Err.(gateapi.GateAPIError); ok {
+		return gateErr.Label
+	}
+	return ""
+}
+
+func newAPIError(operation string, err error) error {
+	return &apiError{Operation: operation, Err: err}
+}
+
+// validationError is a plan or a parameter refused before anything is sent
+type validationError struct {
+	Message string
+}
+
+func (e *validationError) Error() string {
+	return e.Message
+}
+
+func validationErrorf(format string, args ...interface{}) error {
+	return &validationError{Message: fmt.Sprintf(format, args...)}
+}
+
+// parseError is a number the exchange sent that cannot be read
+type parseError struct {
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *parseError) Error() string {
+	return fmt.Sprintf("cannot read %s %q: %s", e.Field, e.Value, e.Err)
+}
+
+func (e *parseError) Unwrap() error {
+	return e.Err
+}
+
+func parseFloatField(field string, value string) (float64, error) {
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, &parseError{Field: field, Value: value, Err: err}
+	}
+	return result, nil
+}
+
+func isValidationError(err error) bool {
+	var target *validationError
+	return errors.As(err, &target)
+}
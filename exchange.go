@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const (
+	GATEIO_EXCHANGE    = "gateio"
+	SIMULATOR_EXCHANGE = "sim"
+)
+
+// Exchange is the set of spot operations used by the bot.
+// Errors are returned as gateapi.GateAPIError when the venue rejected the request.
+type Exchange interface {
+	GetAccountDetail() (gateapi.AccountDetail, error)
+	GetCurrencyPair(pair string) (gateapi.CurrencyPair, error)
+	ListTickers(pair string) ([]gateapi.Ticker, error)
+	ListOrderBook(pair string, options *gateapi.ListOrderBookOpts) (gateapi.OrderBook, error)
+	ListSpotAccounts() ([]gateapi.SpotAccount, error)
+
+	CreateOrder(order gateapi.Order) (gateapi.Order, error)
+	CreateBatchOrders(orders []gateapi.Order) ([]gateapi.BatchOrder, error)
+	CreateSpotPriceTriggeredOrder(order gateapi.SpotPriceTriggeredOrder) (gateapi.TriggerOrderResponse, error)
+
+	ListAllOpenOrders(options *gateapi.ListAllOpenOrdersOpts) ([]gateapi.OpenOrders, error)
+	ListOrders(pair string, status string, options *gateapi.ListOrdersOpts) ([]gateapi.Order, error)
+	GetOrder(pair string, orderId string) (gateapi.Order, error)
+	ListMyTrades(pair string, options *gateapi.ListMyTradesOpts) ([]gateapi.Trade, error)
+	ListSpotPriceTriggeredOrders(status string, options *gateapi.ListSpotPriceTriggeredOrdersOpts) ([]gateapi.SpotPriceTriggeredOrder, error)
+
+	CancelOrder(pair string, orderId string) (gateapi.Order, error)
+	CancelBatchOrders(orders []gateapi.CancelBatchOrder) ([]gateapi.CancelOrderResult, error)
+	CancelSpotPriceTriggeredOrder(orderId string) (gateapi.SpotPriceTriggeredOrder, error)
+}
@@ -3,9 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
-	"math"
-	"os"
 	"strconv"
+	"time"
 
 	"github.com/antihax/optional"
 	"github.com/gateio/gateapi-go/v6"
@@ -20,296 +19,427 @@ const (
 	SL_SELL_RULE string = "<="
 )
 
-const MAX_ELEMENT_PAGE float64 = 100
+// largest page accepted by the listing endpoints
+const MAX_ELEMENT_PAGE int32 = 100
 
-func createOrder(pair string, side string, priceMin float64, priceMax float64, amount float64, steps float64, timeInForce string) []gateapi.Order {
+// largest time range of one finished orders query
+const MAX_HISTORY_WINDOW_SEC int64 = 30 * ONE_DAY_SEC
 
-	var orders []gateapi.Order
+// distance between the stop price and the limit price of a stop-limit order, in percent of the price
+const SL_TRIGGER_OFFSET_PERCENT float64 = 0.25
 
-	numInter := math.Abs(priceMax-priceMin) / steps
-	amountPerOrder := amount / math.Round(numInter)
+// precision and minimums of a currency pair as set by the exchange
+type pairRules struct {
+	PricePrecision  int32
+	AmountPrecision int32
+	MinBaseAmount   float64
+	MinQuoteAmount  float64
+}
 
-	numOrders := 0
-	currentPrice := priceMin
-	totalAmountOrder := 0.0
+// gateioExchange talks to the Gate.io spot api v4
+type gateioExchange struct {
+	client *gateapi.APIClient
+	ctx    context.Context
+}
 
-	if amountPerOrder < 1.0 {
-		fmt.Fprintf(os.Stderr, "\nAmount per order must be higher than 1 USDT\nIncrease the amount. Actual amount per order is %.3f\n", amountPerOrder)
-		os.Exit(1)
+func newGateioExchange(key string, secret string) *gateioExchange {
+	client := gateapi.NewAPIClient(gateapi.NewConfiguration())
+	// uncomment the next line if your are testing against testnet
+	// client.ChangeBasePath("https://fx-api-testnet.gateio.ws/api/v4")
+	ctx := context.Background()
+	// without key only public endpoints are available
+	if key != "" {
+		ctx = context.WithValue(ctx,
+			gateapi.ContextGateAPIV4,
+			gateapi.GateAPIV4{
+				Key:    key,
+				Secret: secret,
+			},
+		)
 	}
 
-	fmt.Printf("%s %.5f USDT between %.5f and %.5f, amount per order: %.4f USDT\n", side, amount, priceMin, priceMax, amountPerOrder)
-	for i := 0; i < int(numInter); i++ {
-		alphAmount := amountPerOrder / currentPrice
-
-		if amount-amountPerOrder <= 0 {
-			break
-		}
-
-		order := gateapi.Order{
-			Text:         generateId(10),
-			CurrencyPair: pair,
-			Side:         side,
-			Price:        strconv.FormatFloat(round(currentPrice, 10000), 'f', -1, 64),
-			Amount:       strconv.FormatFloat(alphAmount, 'f', -1, 64),
-			TimeInForce:  timeInForce,
-		}
+	return &gateioExchange{client: client, ctx: ctx}
+}
 
-		fmt.Printf("price: %.5f ALPH, amount: %.2f ALPH, total: %.4f USDT, amount left: %.2f USDT\n", round(currentPrice, 10000), alphAmount, alphAmount*currentPrice, amount)
+func (g *gateioExchange) GetAccountDetail() (gateapi.AccountDetail, error) {
+	result, _, err := g.client.AccountApi.GetAccountDetail(g.ctx)
+	return result, err
+}
 
-		numOrders++
-		currentPrice += steps
-		amount -= alphAmount * currentPrice
-		totalAmountOrder += alphAmount * currentPrice
-		orders = append(orders, order)
-	}
+func (g *gateioExchange) GetCurrencyPair(pair string) (gateapi.CurrencyPair, error) {
+	result, _, err := g.client.SpotApi.GetCurrencyPair(g.ctx, pair)
+	return result, err
+}
 
-	fmt.Printf("Total amount in order: %.5f USDT\n", totalAmountOrder)
-	return orders
+func (g *gateioExchange) ListTickers(pair string) ([]gateapi.Ticker, error) {
+	result, _, err := g.client.SpotApi.ListTickers(g.ctx, &gateapi.ListTickersOpts{CurrencyPair: optional.NewString(pair)})
+	return result, err
 }
 
-func createOrderAlph(pair string, side string, priceMin float64, priceMax float64, amount float64, steps float64, timeInForce string) []gateapi.Order {
+func (g *gateioExchange) ListOrderBook(pair string, options *gateapi.ListOrderBookOpts) (gateapi.OrderBook, error) {
+	result, _, err := g.client.SpotApi.ListOrderBook(g.ctx, pair, options)
+	return result, err
+}
 
-	var orders []gateapi.Order
+func (g *gateioExchange) ListSpotAccounts() ([]gateapi.SpotAccount, error) {
+	result, _, err := g.client.SpotApi.ListSpotAccounts(g.ctx, nil)
+	return result, err
+}
 
-	numInter := math.Abs(priceMax-priceMin) / steps
-	amountPerOrder := amount / math.Round(numInter)
+func (g *gateioExchange) CreateOrder(order gateapi.Order) (gateapi.Order, error) {
+	result, _, err := g.client.SpotApi.CreateOrder(g.ctx, order)
+	return result, err
+}
 
-	numOrders := 0
-	currentPrice := priceMin
-	totalAmountOrder := 0.0
+func (g *gateioExchange) CreateBatchOrders(orders []gateapi.Order) ([]gateapi.BatchOrder, error) {
+	result, _, err := g.client.SpotApi.CreateBatchOrders(g.ctx, orders)
+	return result, err
+}
 
-	if amountPerOrder*currentPrice < 1.0 {
-		fmt.Fprintf(os.Stderr, "\nAmount per order must be higher than 1 USDT\nIncrease the amount. Actual amount per order is %.3f\n", amountPerOrder)
-		os.Exit(1)
-	}
+func (g *gateioExchange) CreateSpotPriceTriggeredOrder(order gateapi.SpotPriceTriggeredOrder) (gateapi.TriggerOrderResponse, error) {
+	result, _, err := g.client.SpotApi.CreateSpotPriceTriggeredOrder(g.ctx, order)
+	return result, err
+}
 
-	fmt.Printf("%s %.5f ALPH between %.5f and %.5f, amount per order: %.4f ALPH\n", side, amount, priceMin, priceMax, amountPerOrder)
-	for i := 0; i < int(numInter); i++ {
-		alphAmount := amountPerOrder
+func (g *gateioExchange) ListAllOpenOrders(options *gateapi.ListAllOpenOrdersOpts) ([]gateapi.OpenOrders, error) {
+	result, _, err := g.client.SpotApi.ListAllOpenOrders(g.ctx, options)
+	return result, err
+}
 
-		if amount-amountPerOrder <= 0 {
-			break
-		}
+func (g *gateioExchange) ListOrders(pair string, status string, options *gateapi.ListOrdersOpts) ([]gateapi.Order, error) {
+	result, _, err := g.client.SpotApi.ListOrders(g.ctx, pair, status, options)
+	return result, err
+}
 
-		order := gateapi.Order{
-			Text:         generateId(10),
-			CurrencyPair: pair,
-			Side:         side,
-			Price:        strconv.FormatFloat(round(currentPrice, 10000), 'f', -1, 64),
-			Amount:       strconv.FormatFloat(alphAmount, 'f', -1, 64),
-			TimeInForce:  timeInForce,
-		}
+func (g *gateioExchange) ListMyTrades(pair string, options *gateapi.ListMyTradesOpts) ([]gateapi.Trade, error) {
+	opts := gateapi.ListMyTradesOpts{}
+	if options != nil {
+		opts = *options
+	}
+	opts.CurrencyPair = optional.NewString(pair)
+	result, _, err := g.client.SpotApi.ListMyTrades(g.ctx, &opts)
+	return result, err
+}
 
-		fmt.Printf("price: %.5f USDT, amount: %.2f ALPH, total: %.4f USDT, amount left: %.2f ALPH\n", round(currentPrice, 10000), alphAmount, alphAmount, amount)
+func (g *gateioExchange) ListSpotPriceTriggeredOrders(status string, options *gateapi.ListSpotPriceTriggeredOrdersOpts) ([]gateapi.SpotPriceTriggeredOrder, error) {
+	result, _, err := g.client.SpotApi.ListSpotPriceTriggeredOrders(g.ctx, status, options)
+	return result, err
+}
 
-		numOrders++
-		currentPrice += steps
-		amount -= alphAmount
-		totalAmountOrder += alphAmount
-		orders = append(orders, order)
-	}
+func (g *gateioExchange) GetOrder(pair string, orderId string) (gateapi.Order, error) {
+	result, _, err := g.client.SpotApi.GetOrder(g.ctx, orderId, pair, nil)
+	return result, err
+}
 
-	fmt.Printf("Total amount in order: %.5f USDT\n", totalAmountOrder)
-	return orders
+func (g *gateioExchange) CancelOrder(pair string, orderId string) (gateapi.Order, error) {
+	result, _, err := g.client.SpotApi.CancelOrder(g.ctx, orderId, pair, nil)
+	return result, err
 }
 
-func createTriggeredOrder(pair string, side string, priceMin float64, priceMax float64, amount float64, steps float64) []gateapi.SpotPriceTriggeredOrder {
-	var orders []gateapi.SpotPriceTriggeredOrder
+func (g *gateioExchange) CancelBatchOrders(orders []gateapi.CancelBatchOrder) ([]gateapi.CancelOrderResult, error) {
+	result, _, err := g.client.SpotApi.CancelBatchOrders(g.ctx, orders)
+	return result, err
+}
 
-	numInter := math.Abs(priceMax-priceMin) / steps
-	amountPerOrder := amount / math.Round(numInter)
+func (g *gateioExchange) CancelSpotPriceTriggeredOrder(orderId string) (gateapi.SpotPriceTriggeredOrder, error) {
+	result, _, err := g.client.SpotApi.CancelSpotPriceTriggeredOrder(g.ctx, orderId)
+	return result, err
+}
 
-	numOrders := 0
-	currentPrice := priceMin
-	totalAmountOrder := 0.0
-	var expirationSec int32 = ONE_DAY_SEC //one day
+func sendOrder(exchange Exchange, order gateapi.Order) (gateapi.Order, error) {
 
-	rule := SL_BUY_RULE
-	if side == sell {
-		rule = SL_SELL_RULE
+	result, err := exchange.CreateOrder(order)
+	if err != nil {
+		return result, newAPIError("create order", err)
 	}
+	return result, nil
+}
 
-	if amountPerOrder < 1.0 {
-		fmt.Fprintf(os.Stderr, "\nAmount per order must be higher than 1 USDT\nIncrease the amount. Actual amount per order is %.3f\n", amountPerOrder)
-		os.Exit(1)
+func getOrders(exchange Exchange, pair string, status string, options *gateapi.ListOrdersOpts) ([]gateapi.Order, error) {
+	result, err := exchange.ListOrders(pair, status, options)
+	if err != nil {
+		return nil, newAPIError("list "+status+" orders", err)
 	}
+	return result, nil
+}
 
-	fmt.Printf("%s %.5f USDT between %.5f and %.5f, amount per order: %.4f USDT, duration: %d day\n", side, amount, priceMin, priceMax, amountPerOrder, expirationSec/ONE_DAY_SEC)
-	for i := 0; i < int(numInter); i++ {
-		alphAmount := round(amountPerOrder/currentPrice, 1000)
-
-		if amount-amountPerOrder <= 0 {
-			break
-		}
+func checkBalance(exchange Exchange) ([]gateapi.SpotAccount, error) {
 
-		order := gateapi.SpotPriceTriggeredOrder{
-			Market: pair,
-			Put: gateapi.SpotPricePutOrder{
-				Type:        "limit",
-				Side:        side,
-				Price:       strconv.FormatFloat(round(currentPrice, 10000), 'f', -1, 64),
-				Amount:      strconv.FormatFloat(alphAmount, 'f', -1, 64),
-				Account:     "normal",
-				TimeInForce: "ioc",
-			},
-			Trigger: gateapi.SpotPriceTrigger{
-				Price:      strconv.FormatFloat(round(currentPrice-0.001, 10000), 'f', -1, 64),
-				Rule:       rule,
-				Expiration: expirationSec,
-			},
-		}
+	result, err := exchange.ListSpotAccounts()
+	if err != nil {
+		return nil, newAPIError("list balances", err)
+	}
+	return result, nil
+}
 
-		fmt.Printf("price: %.5f ALPH, Stop price: %.4f USDT, amount: %.4f ALPH, total: %.4f USDT, amount left: %.4f USDT\n", round(currentPrice, 10000), round(currentPrice-0.001, 10000), alphAmount, alphAmount*currentPrice, amount)
+// send the orders in one batch and return the result of each order, in request order
+func sendBatchOrder(exchange Exchange, orders []gateapi.Order) ([]gateapi.BatchOrder, error) {
 
-		numOrders++
-		currentPrice += steps
-		amount -= alphAmount * currentPrice
-		totalAmountOrder += alphAmount * currentPrice
-		orders = append(orders, order)
+	result, err := exchange.CreateBatchOrders(orders)
+	if err != nil {
+		// result holds the orders of the batch known to be placed despite the error
+		return result, newAPIError("create batch orders", err)
 	}
+	return result, nil
+}
 
-	fmt.Printf("Total amount in order: %.5f USDT\n", totalAmountOrder)
-	return orders
+// send the orders by batches and report the outcome of each one
+func sendLadderOrders(exchange Exchange, orders []gateapi.Order) *batchReport {
+	report := &batchReport{}
+	for i := 0; i < len(orders); i += GATE_MAX_SIZE_BATCH {
+		end := i + GATE_MAX_SIZE_BATCH
+		if end > len(orders) {
+			end = len(orders)
+		}
 
+		chunk := orders[i:end]
+		result, err := sendBatchOrder(exchange, chunk)
+		if err != nil {
+			report.addFailed(chunk, result, err)
+			continue
+		}
+		report.add(chunk, result)
+	}
+	return report
 }
 
-func sendOrder(client *gateapi.APIClient, ctx *context.Context, orders gateapi.Order) {
+// cancel the orders and return how many were cancelled
+func cancelBatchOrders(exchange Exchange, orders []gateapi.Order) (int, error) {
+	var toCancel []gateapi.CancelBatchOrder
+	for orderIndex := 0; orderIndex < len(orders); orderIndex++ {
+		toCancel = append(toCancel, gateapi.CancelBatchOrder{CurrencyPair: orders[orderIndex].CurrencyPair, Id: orders[orderIndex].Id})
+	}
 
-	result, _, err := client.SpotApi.CreateOrder(*ctx, orders)
+	result, err := exchange.CancelBatchOrders(toCancel)
 	if err != nil {
-		if e, ok := err.(gateapi.GateAPIError); ok {
-			fmt.Printf("gate api error: %s\n", e.Error())
+		return 0, newAPIError("cancel batch orders", err)
+	}
+
+	cancelled := 0
+	for resultIndex := 0; resultIndex < len(result); resultIndex++ {
+		if result[resultIndex].Succeeded {
+			cancelled++
 		} else {
-			fmt.Printf("generic error: %s\n", err.Error())
+			fmt.Printf("Order %s not cancelled: %s %s\n", result[resultIndex].Id, result[resultIndex].Label, result[resultIndex].Message)
 		}
-	} else {
-		fmt.Println(result)
 	}
-
+	return cancelled, nil
 }
 
-func getOrders(client *gateapi.APIClient, ctx *context.Context, pair string, status string, options *gateapi.ListOrdersOpts) []gateapi.Order {
-	result, _, err := client.SpotApi.ListOrders(*ctx, pair, status, options)
-	if err != nil {
-		if e, ok := err.(gateapi.GateAPIError); ok {
-			fmt.Printf("gate api error: %s\n", e.Error())
-		} else {
-			fmt.Printf("generic error: %s\n", err.Error())
+// cancel the triggered orders one by one, there is no batch endpoint for them, and return how many were cancelled
+func cancelTriggeredOrders(exchange Exchange, orders []gateapi.SpotPriceTriggeredOrder) int {
+	cancelled := 0
+	for _, order := range orders {
+		id := strconv.FormatInt(order.Id, 10)
+		if _, err := exchange.CancelSpotPriceTriggeredOrder(id); err != nil {
+			fmt.Printf("Stop-limit order %s not cancelled: %s\n", id, newAPIError("cancel triggered order", err))
+			continue
 		}
-	} else {
-		//fmt.Printf("%+v\n", result)
+		cancelled++
 	}
-
-	return result
+	return cancelled
 }
 
-func checkBalance(client *gateapi.APIClient, ctx *context.Context) []gateapi.SpotAccount {
+// send the triggered order and return its id
+func sendTriggeredOrder(exchange Exchange, spotPriceTriggeredOrder *gateapi.SpotPriceTriggeredOrder) (int64, error) {
 
-	result, _, err := client.SpotApi.ListSpotAccounts(*ctx, nil)
+	result, err := exchange.CreateSpotPriceTriggeredOrder(*spotPriceTriggeredOrder)
 	if err != nil {
-		if e, ok := err.(gateapi.GateAPIError); ok {
-			fmt.Printf("gate api error: %s\n", e.Error())
-			panic(e)
-		} else {
-			fmt.Printf("generic error: %s\n", err.Error())
-			panic(e)
-
-		}
+		return 0, newAPIError("create triggered order", err)
 	}
 
-	return result
+	fmt.Println(result)
+	return result.Id, nil
+}
 
+// every open order of the pair, page by page
+func getOpenOrders(exchange Exchange, currency_pair string) ([]gateapi.Order, error) {
+	orders := []gateapi.Order{}
+	for page := int32(1); ; page++ {
+		result, err := getOrders(exchange, currency_pair, "open", &gateapi.ListOrdersOpts{Page: optional.NewInt32(page), Limit: optional.NewInt32(MAX_ELEMENT_PAGE)})
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, result...)
+		if int32(len(result)) < MAX_ELEMENT_PAGE {
+			return orders, nil
+		}
+	}
 }
 
-func sendBatchOrder(client *gateapi.APIClient, ctx *context.Context, orders []gateapi.Order) {
+// finished orders of the pair, most recent first.
+// Without a time range the latest orders are paged until max is reached (0 for all),
+// a range longer than the exchange allows is queried window by window.
+func getFinishedOrders(exchange Exchange, pair string, side string, from int64, to int64, max int) ([]gateapi.Order, error) {
+	if from <= 0 {
+		options := gateapi.ListOrdersOpts{}
+		if side != "" {
+			// side is only compatible without time range
+			options.Side = optional.NewString(side)
+		}
+		return getOrderPages(exchange, pair, options, max)
+	}
 
-	result, _, err := client.SpotApi.CreateBatchOrders(*ctx, orders)
-	if err != nil {
-		if e, ok := err.(gateapi.GateAPIError); ok {
-			fmt.Printf("gate api error: %s\n", e.Error())
-		} else {
-			fmt.Printf("generic error: %s\n", err.Error())
+	if to <= 0 {
+		to = time.Now().Unix()
+	}
+
+	var orders []gateapi.Order
+	// windows share their bounds, an order on a bound is returned twice
+	seen := map[string]bool{}
+	for windowEnd := to; windowEnd > from; windowEnd -= MAX_HISTORY_WINDOW_SEC {
+		windowStart := windowEnd - MAX_HISTORY_WINDOW_SEC
+		if windowStart < from {
+			windowStart = from
 		}
-	} else {
 
-		for resultIndex := 0; resultIndex < len(result); resultIndex++ {
-			if result[resultIndex].Status == "filled" {
-				orderFilled := result[resultIndex]
-				fmt.Printf("Order get filled: price: %s USDT, amount: %s ALPH, total: %s USDT\n", orderFilled.FillPrice, orderFilled.Amount, orderFilled.FillPrice)
+		options := gateapi.ListOrdersOpts{From: optional.NewInt64(windowStart), To: optional.NewInt64(windowEnd)}
+		result, err := getOrderPages(exchange, pair, options, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, order := range result {
+			if !seen[order.Id] {
+				seen[order.Id] = true
+				orders = append(orders, order)
 			}
 		}
+		if max > 0 && len(orders) >= max {
+			return orders[:max], nil
+		}
 	}
-
+	return orders, nil
 }
 
-func sendTriggeredOrder(client *gateapi.APIClient, ctx *context.Context, spotPriceTriggeredOrder *gateapi.SpotPriceTriggeredOrder) {
-
-	result, _, err := client.SpotApi.CreateSpotPriceTriggeredOrder(*ctx, *spotPriceTriggeredOrder)
-	if err != nil {
-		if e, ok := err.(gateapi.GateAPIError); ok {
-			fmt.Printf("gate api error: %s\n", e.Error())
-		} else {
-			fmt.Printf("generic error: %s\n", err.Error())
+// finished orders matching the options, page by page until max is reached (0 for all)
+func getOrderPages(exchange Exchange, pair string, options gateapi.ListOrdersOpts, max int) ([]gateapi.Order, error) {
+	var orders []gateapi.Order
+	options.Limit = optional.NewInt32(MAX_ELEMENT_PAGE)
+	for page := int32(1); ; page++ {
+		options.Page = optional.NewInt32(page)
+		result, err := getOrders(exchange, pair, "finished", &options)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, result...)
+		if max > 0 && len(orders) >= max {
+			return orders[:max], nil
+		}
+		if int32(len(result)) < MAX_ELEMENT_PAGE {
+			return orders, nil
 		}
-	} else {
-		fmt.Println(result)
 	}
 }
 
-func getOpenOrders(client *gateapi.APIClient, ctx *context.Context, currency_pair string) []gateapi.Order {
-	result, _, err := client.SpotApi.ListAllOpenOrders(*ctx, &gateapi.ListAllOpenOrdersOpts{Limit: optional.NewInt32(100), Page: optional.NewInt32(1)})
+// trades of the pair between from and to, oldest first, queried window by window and page by page
+func getMyTrades(exchange Exchange, pair string, from int64, to int64) ([]gateapi.Trade, error) {
+	var trades []gateapi.Trade
+	// windows share their bounds, a trade on a bound is returned twice
+	seen := map[string]bool{}
+	for windowStart := from; windowStart < to; windowStart += MAX_HISTORY_WINDOW_SEC {
+		windowEnd := windowStart + MAX_HISTORY_WINDOW_SEC
+		if windowEnd > to {
+			windowEnd = to
+		}
 
-	if err != nil {
-		if e, ok := err.(gateapi.GateAPIError); ok {
-			fmt.Printf("gate api error: %s\n", e.Error())
-		} else {
-			fmt.Printf("generic error: %s\n", err.Error())
+		var window []gateapi.Trade
+		for page := int32(1); ; page++ {
+			result, err := exchange.ListMyTrades(pair, &gateapi.ListMyTradesOpts{
+				From:  optional.NewInt64(windowStart),
+				To:    optional.NewInt64(windowEnd),
+				Page:  optional.NewInt32(page),
+				Limit: optional.NewInt32(MAX_ELEMENT_PAGE),
+			})
+			if err != nil {
+				return nil, newAPIError("list my trades", err)
+			}
+			window = append(window, result...)
+			if int32(len(result)) < MAX_ELEMENT_PAGE {
+				break
+			}
 		}
-	}
 
-	if (len(result)) > 0 {
-		for pairIndex := 0; pairIndex < len(result); pairIndex++ {
-			if result[pairIndex].CurrencyPair == currency_pair {
-				return result[pairIndex].Orders
+		// the exchange returns the most recent first
+		for i := len(window) - 1; i >= 0; i-- {
+			if !seen[window[i].Id] {
+				seen[window[i].Id] = true
+				trades = append(trades, window[i])
 			}
 		}
 	}
-	return []gateapi.Order{}
+	return trades, nil
 }
 
-func getTickerPrice(client *gateapi.APIClient, ctx *context.Context, pair string) float64 {
-	result, _, err := client.SpotApi.ListTickers(*ctx, &gateapi.ListTickersOpts{CurrencyPair: optional.NewString(pair)})
-	if err != nil {
-		if e, ok := err.(gateapi.GateAPIError); ok {
-			fmt.Printf("gate api error: %s\n", e.Error())
-		} else {
-			fmt.Printf("generic error: %s\n", err.Error())
+// every stop-limit order of the pair with the status (open or finished), page by page
+func getTriggeredOrders(exchange Exchange, pair string, status string) ([]gateapi.SpotPriceTriggeredOrder, error) {
+	var orders []gateapi.SpotPriceTriggeredOrder
+	for offset := int32(0); ; offset += MAX_ELEMENT_PAGE {
+		result, err := exchange.ListSpotPriceTriggeredOrders(status, &gateapi.ListSpotPriceTriggeredOrdersOpts{
+			Market: optional.NewString(pair),
+			Limit:  optional.NewInt32(MAX_ELEMENT_PAGE),
+			Offset: optional.NewInt32(offset),
+		})
+		if err != nil {
+			return nil, newAPIError("list "+status+" triggered orders", err)
+		}
+		orders = append(orders, result...)
+		if int32(len(result)) < MAX_ELEMENT_PAGE {
+			return orders, nil
 		}
 	}
+}
+
+// last price of the pair, an error is returned rather than a zero price
+func getTickerPrice(exchange Exchange, pair string) (float64, error) {
+	result, err := exchange.ListTickers(pair)
+	if err != nil {
+		return 0.0, newAPIError("list tickers", err)
+	}
 
-	if len(result) > 0 {
-		price, err := strconv.ParseFloat(result[0].Last, 64)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Cannot get price: %s", err)
-		}
+	if len(result) == 0 {
+		return 0.0, newAPIError("list tickers", fmt.Errorf("no ticker returned for %s", pair))
+	}
+
+	price, err := parseFloatField("last price", result[0].Last)
+	if err != nil {
+		return 0.0, err
+	}
+	if price <= 0.0 {
+		return 0.0, newAPIError("list tickers", fmt.Errorf("invalid last price %s for %s", result[0].Last, pair))
+	}
+	return price, nil
+}
 
-		return price
+func getAccountDetails(exchange Exchange) (gateapi.AccountDetail, error) {
+	result, err := exchange.GetAccountDetail()
+	if err != nil {
+		return result, newAPIError("get account detail", err)
 	}
-	return 0.0
+	return result, nil
 }
 
-func getAccountDetails(client *gateapi.APIClient, ctx *context.Context) {
-	result, _, err := client.AccountApi.GetAccountDetail(*ctx)
+func getPairRules(exchange Exchange, pair string) (pairRules, error) {
+	result, err := exchange.GetCurrencyPair(pair)
 	if err != nil {
-		if e, ok := err.(gateapi.GateAPIError); ok {
-			fmt.Printf("gate api error: %s\n", e.Error())
-			panic(e)
-		} else {
-			fmt.Printf("generic error: %s\n", err.Error())
-			panic(e)
-		}
-	} else {
-		fmt.Printf("%+v\n", result)
+		return pairRules{}, newAPIError("get currency pair", err)
+	}
+
+	if result.TradeStatus != "" && result.TradeStatus != "tradable" {
+		return pairRules{}, validationErrorf("pair %s is not tradable (status: %s)", pair, result.TradeStatus)
+	}
+
+	return pairRulesFrom(result), nil
+}
+
+func pairRulesFrom(result gateapi.CurrencyPair) pairRules {
+	// min amounts are omitted by the api when the pair has none
+	minBase, _ := strconv.ParseFloat(result.MinBaseAmount, 64)
+	minQuote, _ := strconv.ParseFloat(result.MinQuoteAmount, 64)
+
+	return pairRules{
+		PricePrecision:  result.Precision,
+		AmountPrecision: result.AmountPrecision,
+		MinBaseAmount:   minBase,
+		MinQuoteAmount:  minQuote,
 	}
 }
@@ -3,7 +3,14 @@ module steps-bot
 go 1.20
 
 require (
-	github.com/antihax/optional v1.0.0 // indirect
-	github.com/gateio/gateapi-go/v6 v6.57.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/antihax/optional v1.0.0
+	github.com/gateio/gateapi-go/v6 v6.57.0
+	github.com/gorilla/websocket v1.5.0
+	github.com/joho/godotenv v1.5.1
+	github.com/shopspring/decimal v1.3.1
+	golang.org/x/crypto v0.16.0
+	golang.org/x/term v0.15.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.15.0 // indirect
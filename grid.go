@@ -0,0 +1,337 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const GRID_COMMAND = "grid"
+
+const DEFAULT_GRID_INTERVAL = 10 * time.Second
+
+var gridInterval time.Duration
+
+// one level of a grid, it holds either a buy at BuyPrice or a sell at SellPrice
+type gridLevel struct {
+	BuyPrice  float64 `json:"buy_price"`
+	SellPrice float64 `json:"sell_price"`
+	Amount    float64 `json:"amount"`
+	Side      string  `json:"side"`
+	OrderId   string  `json:"order_id"`
+	Text      string  `json:"text"`
+
+	// first leg of the current round trip: its side and its value in quote, fees included
+	OpenLeg   string  `json:"open_leg,omitempty"`
+	OpenValue float64 `json:"open_value,omitempty"`
+
+	RoundTrips int     `json:"round_trips"`
+	Profit     float64 `json:"profit"`
+}
+
+type gridState struct {
+	Ladder int         `json:"ladder"`
+	Pair   string      `json:"pair"`
+	Rules  pairRules   `json:"rules"`
+	Levels []gridLevel `json:"levels"`
+}
+
+func gridStatePath(ladder int) (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "grid-"+strconv.Itoa(ladder)+".json"), nil
+}
+
+func loadGridState(ladder int) (gridState, error) {
+	var state gridState
+
+	path, err := gridStatePath(ladder)
+	if err != nil {
+		return state, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(content, &state)
+	return state, err
+}
+
+func saveGridState(state gridState) {
+	path, err := gridStatePath(state.Ladder)
+	if err == nil {
+		var content []byte
+		content, err = json.MarshalIndent(state, "", "  ")
+		if err == nil {
+			err = writeFileAtomic(path, content)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot save the grid state: %s\n", err)
+	}
+}
+
+// place the initial ladder and turn it into a grid state
+func startGrid(exchange Exchange, journal *journalExchange) gridState {
+	rules, err := getPairRules(exchange, pair)
+	exitOnError(err)
+	ticker, amount := ladderAmount()
+
+	fmt.Printf("Here are the orders the grid starts with\n")
+	plan, err := planLadder(pair, side, priceMin, priceMax, amount, ticker, ladderSpacing, rules, ladderDistribution)
+	exitOnError(err)
+	checkPlanBalance(exchange, plan, "")
+	plan.print(LIMIT_ORDER)
+	orders := plan.limitOrders(timeInForce)
+
+	// levels crossing the book are filled immediately as taker
+	report, err := checkRisk(exchange, pair, orders)
+	exitOnError(err)
+	printRiskReport(report, side, rules)
+	exitOnError(checkTakerExposure(report))
+
+	fmt.Printf("\nFilled buys are replaced by sells one step higher and filled sells by buys one step lower.\n")
+	if !confirm("Do you want to start the grid?") {
+		os.Exit(0)
+	}
+	fmt.Println()
+
+	ladder, err := recordLadder(ladderRecord{
+		Exchange:       exchangeName,
+		Account:        accountName,
+		Pair:           pair,
+		Side:           side,
+		PriceMin:       priceMin,
+		PriceMax:       priceMax,
+		Amount:         amount,
+		AmountCurrency: ticker,
+		Spacing:        ladderSpacing,
+		Distribution:   ladderDistribution,
+		TimeInForce:    timeInForce,
+		Orders:         len(orders),
+		Grid:           true,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot record the ladder: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Grid on ladder %d\n", ladder.Id)
+	tagLadderOrders(orders, ladder.Id)
+	journal.ladder = ladder.Id
+	writeJournal(ladder.Id, JOURNAL_PLAN, "", journalPlan{Orders: orders}, nil)
+
+	state := gridState{Ladder: ladder.Id, Pair: pair, Rules: rules}
+	for i := 0; i < len(orders); i += GATE_MAX_SIZE_BATCH {
+		end := i + GATE_MAX_SIZE_BATCH
+		if end > len(orders) {
+			end = len(orders)
+		}
+
+		// on error, results still holds the orders placed before it
+		results, err := sendBatchOrder(exchange, orders[i:end])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Batch not sent, the grid continues without the orders not placed: %s\n", err)
+		}
+		for _, result := range results {
+			if !result.Succeeded {
+				fmt.Printf("Order at %s not placed, the grid continues without it: %s %s\n", result.Price, result.Label, result.Message)
+				continue
+			}
+
+			price, _ := strconv.ParseFloat(result.Price, 64)
+			amount, _ := strconv.ParseFloat(result.Amount, 64)
+			level := gridLevel{Amount: amount, Side: result.Side, OrderId: result.Id, Text: result.Text}
+			if result.Side == buy {
+				level.BuyPrice = price
+				level.SellPrice = quantizePrice(ladderSpacing.next(price, priceMin, priceMax), rules)
+			} else {
+				level.SellPrice = price
+				level.BuyPrice = quantizePrice(ladderSpacing.previous(price, priceMin, priceMax), rules)
+			}
+			state.Levels = append(state.Levels, level)
+		}
+	}
+
+	saveGridState(state)
+	return state
+}
+
+// run the grid until interrupted, advance moves the simulated market before each poll
+func runGrid(exchange Exchange, journal *journalExchange, advance func() bool) {
+	var state gridState
+	if ladderId > 0 {
+		var err error
+		state, err = loadGridState(ladderId)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot resume the grid of ladder %d: %s\n", ladderId, err)
+			os.Exit(1)
+		}
+		journal.ladder = ladderId
+		fmt.Printf("Resuming grid on ladder %d\n", ladderId)
+	} else {
+		state = startGrid(exchange, journal)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(gridInterval)
+	defer ticker.Stop()
+
+	fmt.Printf("Watching %d levels every %s, stop with Ctrl-C\n", len(state.Levels), gridInterval)
+	for {
+		if advance != nil && !advance() {
+			printGridSummary(state)
+			return
+		}
+
+		pollGrid(exchange, &state)
+
+		if advance != nil {
+			continue
+		}
+		select {
+		case <-signals:
+			fmt.Println()
+			printGridSummary(state)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flip every level whose order got filled
+func pollGrid(exchange Exchange, state *gridState) {
+	for i := range state.Levels {
+		level := &state.Levels[i]
+		if level.OrderId == "" {
+			continue
+		}
+
+		order, err := exchange.GetOrder(state.Pair, level.OrderId)
+		if err != nil {
+			fmt.Printf("Cannot check order %s: %s\n", level.OrderId, err)
+			continue
+		}
+		if order.Status == "open" {
+			continue
+		}
+
+		amount, _ := strconv.ParseFloat(order.Amount, 64)
+		left, _ := strconv.ParseFloat(order.Left, 64)
+		if amount-left <= 0 {
+			fmt.Printf("Order %s at %s was %s, level stopped\n", order.Id, order.Price, order.Status)
+			level.OrderId = ""
+			level.Side = ""
+			saveGridState(*state)
+			continue
+		}
+
+		onGridFill(exchange, state, level, order, amount-left)
+		saveGridState(*state)
+	}
+}
+
+func onGridFill(exchange Exchange, state *gridState, level *gridLevel, order gateapi.Order, filled float64) {
+	base, quote, _ := splitPair(state.Pair)
+	price, _ := strconv.ParseFloat(order.AvgDealPrice, 64)
+	total, _ := strconv.ParseFloat(order.FilledTotal, 64)
+	fee, _ := strconv.ParseFloat(order.Fee, 64)
+
+	feeQuote := 0.0
+	feeBase := 0.0
+	if order.FeeCurrency == quote {
+		feeQuote = fee
+	} else if order.FeeCurrency == base {
+		feeBase = fee
+		feeQuote = fee * price
+	}
+
+	next := gateapi.Order{CurrencyPair: state.Pair, TimeInForce: GOOD_TILL_CANCEL}
+	if order.Side == buy {
+		cost := total + feeQuote
+		fmt.Printf("Buy filled: %.4f %s at %s %s\n", filled, base, order.AvgDealPrice, quote)
+		closeGridLeg(level, buy, cost, quote)
+
+		next.Side = sell
+		next.Price = formatAmount(level.SellPrice, state.Rules.PricePrecision)
+		next.Amount = formatAmount(quantizeAmount(filled-feeBase, state.Rules), state.Rules.AmountPrecision)
+	} else {
+		proceeds := total - feeQuote
+		fmt.Printf("Sell filled: %.4f %s at %s %s\n", filled, base, order.AvgDealPrice, quote)
+		closeGridLeg(level, sell, proceeds, quote)
+
+		next.Side = buy
+		next.Price = formatAmount(level.BuyPrice, state.Rules.PricePrecision)
+		next.Amount = formatAmount(level.Amount, state.Rules.AmountPrecision)
+	}
+
+	nextPrice, _ := strconv.ParseFloat(next.Price, 64)
+	nextAmount, _ := strconv.ParseFloat(next.Amount, 64)
+	if nextAmount < state.Rules.MinBaseAmount || nextAmount*nextPrice < state.Rules.MinQuoteAmount || nextAmount <= 0 {
+		fmt.Printf("%s %s %s at %s is below the pair minimums, level stopped\n", next.Side, next.Amount, base, next.Price)
+		level.OrderId = ""
+		level.Side = ""
+		return
+	}
+
+	orders := []gateapi.Order{next}
+	tagLadderOrders(orders, state.Ladder)
+	created, err := exchange.CreateOrder(orders[0])
+	if err != nil {
+		fmt.Printf("Cannot place the %s at %s, level stopped: %s\n", next.Side, next.Price, err)
+		level.OrderId = ""
+		level.Side = ""
+		return
+	}
+
+	fmt.Printf("Placed %s: %s %s at %s %s\n", created.Side, created.Amount, base, created.Price, quote)
+	level.OrderId = created.Id
+	level.Text = created.Text
+	level.Side = created.Side
+}
+
+// record a filled leg, closing the round trip when the opposite leg was already filled
+func closeGridLeg(level *gridLevel, legSide string, value float64, quote string) {
+	if level.OpenLeg == "" || level.OpenLeg == legSide {
+		level.OpenLeg = legSide
+		level.OpenValue = value
+		return
+	}
+
+	profit := value - level.OpenValue
+	if legSide == buy {
+		profit = level.OpenValue - value
+	}
+	level.RoundTrips++
+	level.Profit += profit
+	level.OpenLeg = ""
+	level.OpenValue = 0
+	fmt.Printf("Round trip between %.4f and %.4f: %+.4f %s (level total: %+.4f %s)\n", level.BuyPrice, level.SellPrice, profit, quote, level.Profit, quote)
+}
+
+func printGridSummary(state gridState) {
+	_, quote, _ := splitPair(state.Pair)
+
+	roundTrips := 0
+	profit := 0.0
+	fmt.Printf("Grid on ladder %d\n", state.Ladder)
+	for _, level := range state.Levels {
+		status := level.Side
+		if status == "" {
+			status = "stopped"
+		}
+		fmt.Printf("%.4f - %.4f %s: %d round trips, %+.4f %s\n", level.BuyPrice, level.SellPrice, status, level.RoundTrips, level.Profit, quote)
+		roundTrips += level.RoundTrips
+		profit += level.Profit
+	}
+	fmt.Printf("Total: %d round trips, %+.4f %s\n", roundTrips, profit, quote)
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const (
+	JOURNAL_COMMAND = "journal"
+	RESUME_COMMAND  = "resume"
+)
+
+const JOURNAL_FILE = "journal.jsonl"
+
+const (
+	JOURNAL_PLAN     = "plan"
+	JOURNAL_REQUEST  = "request"
+	JOURNAL_RESPONSE = "response"
+	JOURNAL_ERROR    = "error"
+)
+
+// one line of the journal, payloads are the exchange requests and responses as sent and received
+type journalEntry struct {
+	Time     time.Time       `json:"time"`
+	Exchange string          `json:"exchange"`
+	Ladder   int             `json:"ladder,omitempty"`
+	Kind     string          `json:"kind"`
+	Endpoint string          `json:"endpoint"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// orders of a ladder as planned before sending
+type journalPlan struct {
+	Orders    []gateapi.Order                   `json:"orders,omitempty"`
+	Triggered []gateapi.SpotPriceTriggeredOrder `json:"triggered,omitempty"`
+}
+
+func journalPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, JOURNAL_FILE), nil
+}
+
+// append an entry to the journal, a journal failure is reported but never stops trading
+func writeJournal(ladder int, kind string, endpoint string, payload interface{}, err error) {
+	entry := journalEntry{
+		Time:     time.Now(),
+		Exchange: exchangeName,
+		Ladder:   ladder,
+		Kind:     kind,
+		Endpoint: endpoint,
+	}
+	if payload != nil {
+		content, marshalErr := json.Marshal(payload)
+		if marshalErr != nil {
+			fmt.Fprintf(os.Stderr, "Cannot journal %s %s: %s\n", kind, endpoint, marshalErr)
+			return
+		}
+		entry.Payload = content
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	if writeErr := appendJournal(entry); writeErr != nil {
+		fmt.Fprintf(os.Stderr, "Cannot journal %s %s: %s\n", kind, endpoint, writeErr)
+	}
+}
+
+func appendJournal(entry journalEntry) error {
+	path, err := journalPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	// entries must survive a crash right after the request
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// entries of the ladder, all of them when ladder is 0
+func readJournal(ladder int) ([]journalEntry, error) {
+	path, err := journalPath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return []journalEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// a crash while writing can truncate the last line
+			fmt.Fprintf(os.Stderr, "Skipping journal line %d: %s\n", line, err)
+			continue
+		}
+		if ladder == 0 || entry.Ladder == ladder {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// journalExchange records every request changing orders and its response
+type journalExchange struct {
+	Exchange
+	ladder int
+}
+
+func newJournalExchange(exchange Exchange) *journalExchange {
+	return &journalExchange{Exchange: exchange}
+}
+
+func (j *journalExchange) record(endpoint string, request interface{}, response interface{}, err error) {
+	writeJournal(j.ladder, JOURNAL_REQUEST, endpoint, request, nil)
+	if err != nil {
+		writeJournal(j.ladder, JOURNAL_ERROR, endpoint, nil, err)
+		return
+	}
+	writeJournal(j.ladder, JOURNAL_RESPONSE, endpoint, response, nil)
+}
+
+func (j *journalExchange) CreateOrder(order gateapi.Order) (gateapi.Order, error) {
+	result, err := j.Exchange.CreateOrder(order)
+	j.record("create_order", order, result, err)
+	return result, err
+}
+
+func (j *journalExchange) CreateBatchOrders(orders []gateapi.Order) ([]gateapi.BatchOrder, error) {
+	result, err := j.Exchange.CreateBatchOrders(orders)
+	j.record("create_batch_orders", orders, result, err)
+	return result, err
+}
+
+func (j *journalExchange) CreateSpotPriceTriggeredOrder(order gateapi.SpotPriceTriggeredOrder) (gateapi.TriggerOrderResponse, error) {
+	result, err := j.Exchange.CreateSpotPriceTriggeredOrder(order)
+	j.record("create_triggered_order", order, result, err)
+	return result, err
+}
+
+func (j *journalExchange) CancelOrder(pair string, orderId string) (gateapi.Order, error) {
+	result, err := j.Exchange.CancelOrder(pair, orderId)
+	j.record("cancel_order", gateapi.CancelBatchOrder{CurrencyPair: pair, Id: orderId}, result, err)
+	return result, err
+}
+
+func (j *journalExchange) CancelBatchOrders(orders []gateapi.CancelBatchOrder) ([]gateapi.CancelOrderResult, error) {
+	result, err := j.Exchange.CancelBatchOrders(orders)
+	j.record("cancel_batch_orders", orders, result, err)
+	return result, err
+}
+
+func (j *journalExchange) CancelSpotPriceTriggeredOrder(orderId string) (gateapi.SpotPriceTriggeredOrder, error) {
+	result, err := j.Exchange.CancelSpotPriceTriggeredOrder(orderId)
+	j.record("cancel_triggered_order", orderId, result, err)
+	return result, err
+}
+
+func checkResumeArgs() {
+	if ladderId <= 0 {
+		fmt.Fprintf(os.Stderr, "resume needs the -ladder to resume\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+func printJournal() {
+	entries, err := readJournal(ladderId)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read the journal: %s\n", err)
+		os.Exit(1)
+	}
+
+	for _, entry := range entries {
+		detail := string(entry.Payload)
+		if entry.Error != "" {
+			detail = entry.Error
+		}
+		fmt.Printf("%s %s ladder %d %s %s: %s\n", entry.Time.Format(time.RFC3339), entry.Exchange, entry.Ladder, entry.Kind, entry.Endpoint, detail)
+	}
+}
+
+// send the orders of the last plan of a ladder that never reached the exchange
+func runResume(exchange Exchange) {
+	entries, err := readJournal(ladderId)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read the journal: %s\n", err)
+		os.Exit(1)
+	}
+
+	var plan *journalPlan
+	var plannedAt time.Time
+	for _, entry := range entries {
+		if entry.Kind != JOURNAL_PLAN {
+			continue
+		}
+		plannedAt = entry.Time
+		plan = &journalPlan{}
+		if err := json.Unmarshal(entry.Payload, plan); err != nil {
+			fmt.Fprintf(os.Stderr, "Plan of ladder %d is corrupted: %s\n", ladderId, err)
+			os.Exit(1)
+		}
+	}
+	if plan == nil {
+		fmt.Fprintf(os.Stderr, "No plan journaled for ladder %d\n", ladderId)
+		os.Exit(1)
+	}
+	if len(plan.Triggered) > 0 {
+		fmt.Fprintf(os.Stderr, "Ladder %d uses stop-limit orders, they have no text to reconcile and cannot be resumed\n", ladderId)
+		os.Exit(1)
+	}
+	if len(plan.Orders) == 0 {
+		fmt.Printf("Ladder %d has no order\n", ladderId)
+		return
+	}
+
+	// an order is on the exchange if an open or finished order carries its text
+	orderPair := plan.Orders[0].CurrencyPair
+	known := map[string]bool{}
+	openOrders, err := getOpenOrders(exchange, orderPair)
+	exitOnError(err)
+	// orders of the ladder cannot have finished before its plan
+	finishedOrders, err := getFinishedOrders(exchange, orderPair, "", plannedAt.Unix()-ONE_DAY_SEC, 0, 0)
+	exitOnError(err)
+	for _, order := range openOrders {
+		known[order.Text] = true
+	}
+	for _, order := range finishedOrders {
+		known[order.Text] = true
+	}
+
+	var missing []gateapi.Order
+	for _, order := range plan.Orders {
+		if !known[order.Text] {
+			missing = append(missing, order)
+		}
+	}
+
+	if len(missing) == 0 {
+		fmt.Printf("All %d orders of ladder %d reached the exchange\n", len(plan.Orders), ladderId)
+		return
+	}
+
+	base, quote, _ := splitPair(orderPair)
+	fmt.Printf("%d/%d orders of ladder %d are missing\n", len(missing), len(plan.Orders), ladderId)
+	for _, order := range missing {
+		fmt.Printf("%s price: %s %s, amount: %s %s\n", order.Side, order.Price, quote, order.Amount, base)
+	}
+
+	fmt.Println()
+	if !confirm("Send them?") {
+		return
+	}
+	fmt.Println()
+
+	report := sendLadderOrders(exchange, missing)
+	report.print(orderPair)
+	if report.failed() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+const KEYS_COMMAND = "keys"
+
+const (
+	KEYS_ADD    = "add"
+	KEYS_LIST   = "list"
+	KEYS_REMOVE = "remove"
+)
+
+const KEYSTORE_FILE = "keys.json"
+
+// passphrase of the keystore for unattended runs
+const KEYSTORE_PASSPHRASE_ENV = "STEPS_KEYSTORE_PASSPHRASE"
+
+// account name given to -key-cmd in its environment
+const KEY_CMD_ACCOUNT_ENV = "STEPS_KEY_ACCOUNT"
+
+// scrypt cost, the parameters recommended for interactive logins
+const (
+	SCRYPT_N       = 1 << 15
+	SCRYPT_R       = 8
+	SCRYPT_P       = 1
+	SCRYPT_KEY_LEN = 32
+	SCRYPT_SALT    = 16
+)
+
+var keyCommand string
+var keyFd int
+
+// keys subcommand action and account, given after the command
+var keysArgs []string
+
+// passphrase typed once for the whole run
+var keystorePassphrase []byte
+
+// keystoreEntry holds the api keys of one account, encrypted with AES-256-GCM
+// under a key derived from the passphrase by scrypt. The account name is
+// authenticated with the keys so an entry cannot be moved to another account.
+type keystoreEntry struct {
+	Kdf        string `json:"kdf"`
+	N          int    `json:"n"`
+	R          int    `json:"r"`
+	P          int    `json:"p"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+type storedKeys struct {
+	Key    string `json:"key"`
+	Secret string `json:"secret"`
+}
+
+func keystorePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, KEYSTORE_FILE), nil
+}
+
+func loadKeystore() (map[string]keystoreEntry, error) {
+	entries := map[string]keystoreEntry{}
+
+	path, err := keystorePath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("cannot read the keystore %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+func saveKeystore(entries map[string]keystoreEntry) error {
+	path, err := keystorePath()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, content)
+}
+
+func keystoreAccounts() []string {
+	entries, err := loadKeystore()
+	if err != nil {
+		return nil
+	}
+	var accounts []string
+	for account := range entries {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+func keystoreCipher(passphrase []byte, entry keystoreEntry) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, entry.Salt, entry.N, entry.R, entry.P, SCRYPT_KEY_LEN)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptKeys(account string, keys storedKeys, passphrase []byte) (keystoreEntry, error) {
+	entry := keystoreEntry{Kdf: "scrypt", N: SCRYPT_N, R: SCRYPT_R, P: SCRYPT_P, Salt: make([]byte, SCRYPT_SALT)}
+	if _, err := rand.Read(entry.Salt); err != nil {
+		return entry, err
+	}
+	aead, err := keystoreCipher(passphrase, entry)
+	if err != nil {
+		return entry, err
+	}
+	entry.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(entry.Nonce); err != nil {
+		return entry, err
+	}
+
+	plaintext, err := json.Marshal(keys)
+	if err != nil {
+		return entry, err
+	}
+	entry.Ciphertext = aead.Seal(nil, entry.Nonce, plaintext, []byte(account))
+	return entry, nil
+}
+
+func decryptKeys(account string, entry keystoreEntry, passphrase []byte) (storedKeys, error) {
+	var keys storedKeys
+	if entry.Kdf != "scrypt" {
+		return keys, fmt.Errorf("unknown key derivation %s for account %s", entry.Kdf, account)
+	}
+	aead, err := keystoreCipher(passphrase, entry)
+	if err != nil {
+		return keys, err
+	}
+	plaintext, err := aead.Open(nil, entry.Nonce, entry.Ciphertext, []byte(account))
+	if err != nil {
+		return keys, validationErrorf("cannot decrypt the keys of account %s, wrong passphrase", account)
+	}
+	err = json.Unmarshal(plaintext, &keys)
+	return keys, err
+}
+
+// one passphrase for the whole keystore, checked against any stored entry
+func checkPassphrase(entries map[string]keystoreEntry, passphrase []byte) error {
+	for account, entry := range entries {
+		_, err := decryptKeys(account, entry, passphrase)
+		return err
+	}
+	return nil
+}
+
+// read a line without echo from the terminal, or a plain line from a pipe
+func readSecret(prompt string) (string, error) {
+	if !stdinIsTerminal() {
+		if !stdinScanner.Scan() {
+			return "", validationErrorf("%s: nothing to read on stdin", strings.TrimSuffix(prompt, ": "))
+		}
+		return strings.TrimSpace(stdinScanner.Text()), nil
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	secret, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return strings.TrimSpace(string(secret)), err
+}
+
+// passphrase from the environment or typed once, twice when the keystore is created
+func readPassphrase(create bool) ([]byte, error) {
+	if keystorePassphrase != nil {
+		return keystorePassphrase, nil
+	}
+	if passphrase := os.Getenv(KEYSTORE_PASSPHRASE_ENV); passphrase != "" {
+		keystorePassphrase = []byte(passphrase)
+		return keystorePassphrase, nil
+	}
+	requireTerminal(KEYSTORE_PASSPHRASE_ENV)
+
+	passphrase, err := readSecret("Keystore passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if passphrase == "" {
+		return nil, validationErrorf("the passphrase cannot be empty")
+	}
+	if create {
+		again, err := readSecret("Repeat the passphrase: ")
+		if err != nil {
+			return nil, err
+		}
+		if again != passphrase {
+			return nil, validationErrorf("the passphrases do not match")
+		}
+	}
+	keystorePassphrase = []byte(passphrase)
+	return keystorePassphrase, nil
+}
+
+// key on the first line, secret on the second
+func parseKeyLines(reader io.Reader, source string) (string, string, error) {
+	scanner := bufio.NewScanner(reader)
+	var lines []string
+	for len(lines) < 2 && scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+	if len(lines) < 2 {
+		return "", "", validationErrorf("%s must give the api key on the first line and the secret on the second", source)
+	}
+	return lines[0], lines[1], nil
+}
+
+// run -key-cmd through the shell, the account is given in STEPS_KEY_ACCOUNT
+func keysFromCommand(account string) (string, string, error) {
+	cmd := exec.Command("sh", "-c", keyCommand)
+	cmd.Env = append(os.Environ(), KEY_CMD_ACCOUNT_ENV+"="+accountLabel(account))
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("key command failed: %w", err)
+	}
+	return parseKeyLines(strings.NewReader(string(output)), "-key-cmd")
+}
+
+func keysFromFd() (string, string, error) {
+	file := os.NewFile(uintptr(keyFd), fmt.Sprintf("fd%d", keyFd))
+	if _, err := file.Stat(); err != nil {
+		return "", "", validationErrorf("file descriptor %d is not open", keyFd)
+	}
+	defer file.Close()
+	return parseKeyLines(file, fmt.Sprintf("-key-fd %d", keyFd))
+}
+
+func keysFromKeystore(account string) (string, string, bool, error) {
+	entries, err := loadKeystore()
+	if err != nil {
+		return "", "", false, err
+	}
+	entry, ok := entries[accountLabel(account)]
+	if !ok {
+		return "", "", false, nil
+	}
+	passphrase, err := readPassphrase(false)
+	if err != nil {
+		return "", "", true, err
+	}
+	keys, err := decryptKeys(accountLabel(account), entry, passphrase)
+	return keys.Key, keys.Secret, true, err
+}
+
+func runKeys() {
+	action := keysArgs[0]
+	switch action {
+	case KEYS_LIST:
+		accounts := keystoreAccounts()
+		if len(accounts) == 0 {
+			fmt.Println("No key stored")
+			return
+		}
+		for _, account := range accounts {
+			fmt.Println(account)
+		}
+
+	case KEYS_ADD:
+		account := keysArgs[1]
+		entries, err := loadKeystore()
+		exitOnError(err)
+		passphrase, err := readPassphrase(len(entries) == 0)
+		exitOnError(err)
+		exitOnError(checkPassphrase(entries, passphrase))
+
+		key, err := readSecret("Api key: ")
+		exitOnError(err)
+		secret, err := readSecret("Api secret: ")
+		exitOnError(err)
+		if key == "" || secret == "" {
+			exitOnError(validationErrorf("the api key and the secret cannot be empty"))
+		}
+
+		entry, err := encryptKeys(account, storedKeys{Key: key, Secret: secret}, passphrase)
+		exitOnError(err)
+		_, replaced := entries[account]
+		entries[account] = entry
+		exitOnError(saveKeystore(entries))
+		if replaced {
+			fmt.Printf("Keys of account %s replaced\n", account)
+		} else {
+			fmt.Printf("Keys of account %s stored\n", account)
+		}
+
+	case KEYS_REMOVE:
+		account := keysArgs[1]
+		entries, err := loadKeystore()
+		exitOnError(err)
+		if _, ok := entries[account]; !ok {
+			exitOnError(validationErrorf("no key stored for account %s", account))
+		}
+		if !confirm(fmt.Sprintf("Remove the keys of account %s?", account)) {
+			os.Exit(0)
+		}
+		delete(entries, account)
+		exitOnError(saveKeystore(entries))
+		fmt.Printf("Keys of account %s removed\n", account)
+	}
+}
+
+func checkKeysArgs() {
+	if len(keysArgs) == 0 {
+		fmt.Fprintf(os.Stderr, "keys needs an action: add, list or remove\n")
+		os.Exit(1)
+	}
+	switch keysArgs[0] {
+	case KEYS_LIST:
+	case KEYS_ADD, KEYS_REMOVE:
+		if len(keysArgs) < 2 {
+			fmt.Fprintf(os.Stderr, "keys %s needs an account name\n", keysArgs[0])
+			os.Exit(1)
+		}
+		keysArgs[1] = accountLabel(keysArgs[1])
+	default:
+		fmt.Fprintf(os.Stderr, "keys accepted action. add, list or remove\n")
+		os.Exit(1)
+	}
+}
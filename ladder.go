@@ -0,0 +1,277 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const LADDERS_COMMAND = "ladders"
+
+const LADDERS_FILE = "ladders.json"
+
+// Gate.io rejects order texts longer than this
+const GATE_MAX_TEXT_LENGTH int = 28
+
+// random part of the order text after the ladder tag
+const LADDER_TEXT_RANDOM_LENGTH int = 8
+
+var ladderId int
+
+// parameters of one ladder run, saved before its orders are sent
+type ladderRecord struct {
+	Id                int          `json:"id"`
+	Exchange          string       `json:"exchange"`
+	Account           string       `json:"account,omitempty"`
+	Pair              string       `json:"pair"`
+	Side              string       `json:"side"`
+	PriceMin          float64      `json:"price_min"`
+	PriceMax          float64      `json:"price_max"`
+	Amount            float64      `json:"amount"`
+	AmountCurrency    string       `json:"amount_currency"`
+	Spacing           spacing      `json:"spacing"`
+	Distribution      distribution `json:"distribution"`
+	TimeInForce       string       `json:"time_in_force"`
+	StopLimit         bool         `json:"stop_limit"`
+	Grid              bool         `json:"grid,omitempty"`
+	Orders            int          `json:"orders"`
+	TriggeredOrderIds []int64      `json:"triggered_order_ids,omitempty"`
+	CreatedAt         time.Time    `json:"created_at"`
+}
+
+// directory of the persistent state, $XDG_DATA_HOME/steps-bot or ~/.local/share/steps-bot
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "steps-bot"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "steps-bot"), nil
+}
+
+// write then rename so a crash never leaves a half written file
+func writeFileAtomic(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func laddersPath() (string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, LADDERS_FILE), nil
+}
+
+func loadLadders() ([]ladderRecord, error) {
+	path, err := laddersPath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []ladderRecord{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var ladders []ladderRecord
+	if err := json.Unmarshal(content, &ladders); err != nil {
+		return nil, fmt.Errorf("%s is corrupted: %w", path, err)
+	}
+	return ladders, nil
+}
+
+func saveLadders(ladders []ladderRecord) error {
+	path, err := laddersPath()
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(ladders, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, content)
+}
+
+// give the record the next ladder id and save it
+func recordLadder(record ladderRecord) (ladderRecord, error) {
+	ladders, err := loadLadders()
+	if err != nil {
+		return record, err
+	}
+
+	record.Id = 1
+	for _, ladder := range ladders {
+		if ladder.Id >= record.Id {
+			record.Id = ladder.Id + 1
+		}
+	}
+	record.CreatedAt = time.Now()
+
+	return record, saveLadders(append(ladders, record))
+}
+
+func updateLadder(record ladderRecord) error {
+	ladders, err := loadLadders()
+	if err != nil {
+		return err
+	}
+	for i := range ladders {
+		if ladders[i].Id == record.Id {
+			ladders[i] = record
+		}
+	}
+	return saveLadders(ladders)
+}
+
+func findLadder(id int) (ladderRecord, error) {
+	ladders, err := loadLadders()
+	if err != nil {
+		return ladderRecord{}, err
+	}
+	for _, ladder := range ladders {
+		if ladder.Id == id {
+			return ladder, nil
+		}
+	}
+	return ladderRecord{}, validationErrorf("ladder %d is not recorded, see steps ladders", id)
+}
+
+// a ladder is resumed with the keys of the account that placed it, -account may only confirm it
+func useLadderAccount(id int) error {
+	ladder, err := findLadder(id)
+	if err != nil {
+		return err
+	}
+	if accountName != "" && accountLabel(accountName) != accountLabel(ladder.Account) {
+		return validationErrorf("ladder %d was placed on account %s, not %s", id, accountLabel(ladder.Account), accountLabel(accountName))
+	}
+	accountName = ladder.Account
+	return nil
+}
+
+// prefix of the text of every order of a ladder
+func ladderTag(id int) string {
+	return "t-L" + strconv.Itoa(id) + "-"
+}
+
+// ladder id from an order text, 0 when the order is not part of a ladder
+func ladderIdFromText(text string) int {
+	if !strings.HasPrefix(text, "t-L") {
+		return 0
+	}
+	value, _, found := strings.Cut(strings.TrimPrefix(text, "t-L"), "-")
+	if !found {
+		return 0
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// set the ladder tag on every order, keeping the text unique per order
+func tagLadderOrders(orders []gateapi.Order, id int) {
+	tag := ladderTag(id)
+	randomLength := LADDER_TEXT_RANDOM_LENGTH
+	if len(tag)+randomLength > GATE_MAX_TEXT_LENGTH {
+		randomLength = GATE_MAX_TEXT_LENGTH - len(tag)
+	}
+
+	for i := range orders {
+		// generateId adds its own "t-" prefix
+		orders[i].Text = tag + strings.TrimPrefix(generateId(randomLength), "t-")
+	}
+}
+
+// keep the orders of the ladder selected with -ladder, all of them when unset
+func filterLadderOrders(orders []gateapi.Order) []gateapi.Order {
+	if ladderId == 0 {
+		return orders
+	}
+
+	var selected []gateapi.Order
+	for _, order := range orders {
+		if ladderIdFromText(order.Text) == ladderId {
+			selected = append(selected, order)
+		}
+	}
+	return selected
+}
+
+// keep the stop-limit orders of the ladder selected with -ladder, all of them when unset.
+// They carry no text, the ladder is matched on the ids recorded when they were sent.
+func filterLadderTriggeredOrders(orders []gateapi.SpotPriceTriggeredOrder) ([]gateapi.SpotPriceTriggeredOrder, error) {
+	if ladderId == 0 {
+		return orders, nil
+	}
+
+	ladders, err := loadLadders()
+	if err != nil {
+		return nil, err
+	}
+	ids := map[int64]bool{}
+	for _, ladder := range ladders {
+		if ladder.Id == ladderId {
+			for _, id := range ladder.TriggeredOrderIds {
+				ids[id] = true
+			}
+		}
+	}
+
+	var selected []gateapi.SpotPriceTriggeredOrder
+	for _, order := range orders {
+		if ids[order.Id] {
+			selected = append(selected, order)
+		}
+	}
+	return selected, nil
+}
+
+func printLadders() {
+	ladders, err := loadLadders()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot read ladders: %s\n", err)
+		os.Exit(1)
+	}
+
+	if len(ladders) == 0 {
+		fmt.Println("No ladder recorded")
+		return
+	}
+
+	for _, ladder := range ladders {
+		orderType := "limit"
+		if ladder.StopLimit {
+			orderType = "stop-limit"
+		} else if ladder.Grid {
+			orderType = "grid"
+		}
+		exchange := ladder.Exchange
+		if ladder.Account != "" {
+			exchange += " account " + ladder.Account
+		}
+		fmt.Printf("ladder %d: %s %s %.4f %s between %s and %s, %d %s orders on %s (created at: %s)\n",
+			ladder.Id, ladder.Side, ladder.Pair, ladder.Amount, ladder.AmountCurrency,
+			strconv.FormatFloat(ladder.PriceMin, 'f', -1, 64), strconv.FormatFloat(ladder.PriceMax, 'f', -1, 64),
+			ladder.Orders, orderType, exchange, ladder.CreatedAt.Format(time.RFC3339))
+	}
+}
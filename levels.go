@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/shopspring/decimal"
+)
+
+// digits kept by the divisions of the ladder math, far below any pair precision
+const LADDER_DIVISION_PRECISION int32 = 16
+
+func amountUnit(rules pairRules) decimal.Decimal {
+	return decimal.New(1, -rules.AmountPrecision)
+}
+
+// number of levels between priceMin and priceMax, one order per interval starting at priceMin
+func (s spacing) levelCount(priceMin float64, priceMax float64) int {
+	if s.Orders > 0 {
+		return s.Orders
+	}
+	if s.Percent > 0 {
+		// absorb float noise such as 5.9999999999 before flooring
+		return int(math.Floor(s.intervals(priceMin, priceMax) + 1e-9))
+	}
+	// in decimal so 0.06 / 0.01 is 6 and not 5.999
+	width := decimal.NewFromFloat(priceMax).Sub(decimal.NewFromFloat(priceMin)).Abs()
+	return int(width.DivRound(decimal.NewFromFloat(s.Step), LADDER_DIVISION_PRECISION).Floor().IntPart())
+}
+
+// price of level i, computed from priceMin rather than accumulated level after level
+func (s spacing) levelPrice(i int, priceMin float64, priceMax float64, rules pairRules) decimal.Decimal {
+	min := decimal.NewFromFloat(priceMin)
+	var price decimal.Decimal
+	switch {
+	case s.Orders > 0 && s.isGeometric():
+		price = decimal.NewFromFloat(priceMin * math.Pow(priceMax/priceMin, float64(i)/float64(s.Orders)))
+	case s.Orders > 0:
+		step := decimal.NewFromFloat(priceMax).Sub(min).DivRound(decimal.NewFromInt(int64(s.Orders)), LADDER_DIVISION_PRECISION)
+		price = min.Add(step.Mul(decimal.NewFromInt(int64(i))))
+	case s.Percent > 0:
+		price = decimal.NewFromFloat(priceMin * math.Pow(1+s.Percent/100, float64(i)))
+	default:
+		price = min.Add(decimal.NewFromFloat(s.Step).Mul(decimal.NewFromInt(int64(i))))
+	}
+	return price.Round(rules.PricePrecision)
+}
+
+func ladderPrices(s spacing, priceMin float64, priceMax float64, rules pairRules) []decimal.Decimal {
+	count := s.levelCount(priceMin, priceMax)
+	prices := make([]decimal.Decimal, count)
+	for i := range prices {
+		prices[i] = s.levelPrice(i, priceMin, priceMax, rules)
+	}
+	return prices
+}
+
+// a spacing finer than the pair tick rounds several levels to the same price
+func checkDistinctPrices(prices []decimal.Decimal, rules pairRules) error {
+	for i := 1; i < len(prices); i++ {
+		if prices[i].Equal(prices[i-1]) {
+			return validationErrorf("levels %d and %d are both at %s, the spacing is finer than the price precision of the pair (%s), widen the steps or use fewer orders", i, i+1, prices[i].StringFixed(rules.PricePrecision), decimal.New(1, -rules.PricePrecision).String())
+		}
+	}
+	return nil
+}
+
+// part of the total each level receives, in proportion to its weight
+func levelShares(total decimal.Decimal, weights []float64) []decimal.Decimal {
+	sum := decimal.Zero
+	decimalWeights := make([]decimal.Decimal, len(weights))
+	for i, weight := range weights {
+		decimalWeights[i] = decimal.NewFromFloat(weight)
+		sum = sum.Add(decimalWeights[i])
+	}
+
+	shares := make([]decimal.Decimal, len(weights))
+	for i := range shares {
+		shares[i] = total.Mul(decimalWeights[i]).DivRound(sum, LADDER_DIVISION_PRECISION)
+	}
+	return shares
+}
+
+// levels sorted by decreasing weight, the heaviest get the rounding leftovers first
+func byWeight(weights []float64) []int {
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return weights[order[a]] > weights[order[b]]
+	})
+	return order
+}
+
+// split a base amount over the levels, the amounts add up exactly to total
+// (to total rounded down to the amount precision when it is finer)
+func splitBaseAmount(total decimal.Decimal, weights []float64, rules pairRules) []decimal.Decimal {
+	amounts := levelShares(total, weights)
+	allocated := decimal.Zero
+	for i := range amounts {
+		amounts[i] = amounts[i].RoundFloor(rules.AmountPrecision)
+		allocated = allocated.Add(amounts[i])
+	}
+
+	// every level was rounded down by less than one unit, the leftover is a few units at most
+	unit := amountUnit(rules)
+	leftover := total.Sub(allocated)
+	for _, i := range byWeight(weights) {
+		if leftover.LessThan(unit) {
+			break
+		}
+		amounts[i] = amounts[i].Add(unit)
+		leftover = leftover.Sub(unit)
+	}
+	return amounts
+}
+
+// split a quote amount over the levels as base amounts bought or sold at each price.
+// The quote spent never exceeds total and what is left could not buy one more unit at any level.
+func splitQuoteAmount(total decimal.Decimal, prices []decimal.Decimal, weights []float64, rules pairRules) []decimal.Decimal {
+	shares := levelShares(total, weights)
+	amounts := make([]decimal.Decimal, len(shares))
+	spent := decimal.Zero
+	for i := range shares {
+		if !prices[i].IsPositive() {
+			amounts[i] = decimal.Zero
+			continue
+		}
+		amounts[i] = shares[i].DivRound(prices[i], LADDER_DIVISION_PRECISION).RoundFloor(rules.AmountPrecision)
+		spent = spent.Add(amounts[i].Mul(prices[i]))
+	}
+
+	unit := amountUnit(rules)
+	leftover := total.Sub(spent)
+	for added := true; added; {
+		added = false
+		for _, i := range byWeight(weights) {
+			cost := prices[i].Mul(unit)
+			if cost.IsPositive() && cost.LessThanOrEqual(leftover) {
+				amounts[i] = amounts[i].Add(unit)
+				leftover = leftover.Sub(cost)
+				added = true
+			}
+		}
+	}
+	return amounts
+}
+
+func quoteTotal(prices []decimal.Decimal, amounts []decimal.Decimal) decimal.Decimal {
+	total := decimal.Zero
+	for i := range amounts {
+		total = total.Add(prices[i].Mul(amounts[i]))
+	}
+	return total
+}
+
+func baseTotal(amounts []decimal.Decimal) decimal.Decimal {
+	total := decimal.Zero
+	for _, amount := range amounts {
+		total = total.Add(amount)
+	}
+	return total
+}
+
+// the invariant of every ladder: a base ladder places exactly the requested amount,
+// a quote ladder never spends more and leaves less than one amount unit at its cheapest level
+func checkLadderTotal(requested decimal.Decimal, prices []decimal.Decimal, amounts []decimal.Decimal, inQuote bool, rules pairRules) error {
+	if !inQuote {
+		if total := baseTotal(amounts); !total.Equal(requested.RoundFloor(rules.AmountPrecision)) {
+			return fmt.Errorf("ladder invariant broken: places %s instead of %s", total.String(), requested.String())
+		}
+		return nil
+	}
+
+	spent := quoteTotal(prices, amounts)
+	if spent.GreaterThan(requested) {
+		return fmt.Errorf("ladder invariant broken: spends %s, more than %s", spent.String(), requested.String())
+	}
+	for _, price := range prices {
+		if price.IsPositive() && requested.Sub(spent).GreaterThanOrEqual(price.Mul(amountUnit(rules))) {
+			return fmt.Errorf("ladder invariant broken: spends %s of %s, one more unit fits at %s", spent.String(), requested.String(), price.String())
+		}
+	}
+	return nil
+}
+
+// prices and base amounts of the levels of a ladder, amount is in quote currency when inQuote
+func ladderLevels(pair string, side string, priceMin float64, priceMax float64, amount float64, inQuote bool, ladderSpacing spacing, rules pairRules, dist distribution) ([]decimal.Decimal, []decimal.Decimal, error) {
+	base, quote, _ := splitPair(pair)
+
+	prices := ladderPrices(ladderSpacing, priceMin, priceMax, rules)
+	if len(prices) == 0 {
+		return nil, nil, nil
+	}
+	if err := checkDistinctPrices(prices, rules); err != nil {
+		return nil, nil, err
+	}
+	weights, err := ladderWeights(dist, side, len(prices))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	amountPerOrder := amount / float64(len(prices))
+	if inQuote && amountPerOrder < rules.MinQuoteAmount {
+		return nil, nil, validationErrorf("amount per order must be higher than %s %s, increase the amount. Actual amount per order is %.3f", formatAmount(rules.MinQuoteAmount, rules.PricePrecision), quote, amountPerOrder)
+	}
+	if !inQuote && (amountPerOrder < rules.MinBaseAmount || amountPerOrder*priceMin < rules.MinQuoteAmount) {
+		return nil, nil, validationErrorf("amount per order must be higher than %s %s and %s %s, increase the amount. Actual amount per order is %.3f %s", formatAmount(rules.MinBaseAmount, rules.AmountPrecision), base, formatAmount(rules.MinQuoteAmount, rules.PricePrecision), quote, amountPerOrder, base)
+	}
+
+	total := decimal.NewFromFloat(amount)
+	var amounts []decimal.Decimal
+	if inQuote {
+		amounts = splitQuoteAmount(total, prices, weights, rules)
+	} else {
+		amounts = splitBaseAmount(total, weights, rules)
+	}
+	if err := checkLadderTotal(total, prices, amounts, inQuote, rules); err != nil {
+		return nil, nil, err
+	}
+
+	for i := range prices {
+		price, _ := prices[i].Float64()
+		baseAmount, _ := amounts[i].Float64()
+		if err := checkPairMinimums(rules, pair, price, baseAmount); err != nil {
+			return nil, nil, err
+		}
+	}
+	return prices, amounts, nil
+}
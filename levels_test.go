@@ -0,0 +1,196 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+const LEVELS_PROPERTY_RUNS = 2000
+
+// a random ladder: pair precisions, price range, spacing, distribution and amount
+type randomLadder struct {
+	rules    pairRules
+	side     string
+	priceMin float64
+	priceMax float64
+	spacing  spacing
+	dist     distribution
+	amount   float64
+	inQuote  bool
+}
+
+func roundTo(value float64, precision int32) float64 {
+	rounded, _ := decimal.NewFromFloat(value).Round(precision).Float64()
+	return rounded
+}
+
+func newRandomLadder(random *rand.Rand) randomLadder {
+	ladder := randomLadder{
+		rules: pairRules{
+			PricePrecision:  int32(2 + random.Intn(7)),
+			AmountPrecision: int32(random.Intn(7)),
+			MinQuoteAmount:  1,
+		},
+		side:    []string{buy, sell}[random.Intn(2)],
+		inQuote: random.Intn(2) == 0,
+	}
+
+	// from a fraction of a cent to a few hundreds
+	ladder.priceMin = roundTo(math.Pow(10, -2+random.Float64()*4.5), ladder.rules.PricePrecision)
+	if ladder.priceMin <= 0 {
+		ladder.priceMin = math.Pow10(-int(ladder.rules.PricePrecision))
+	}
+	ladder.priceMax = roundTo(ladder.priceMin*(1.01+random.Float64()*0.5), ladder.rules.PricePrecision)
+
+	switch random.Intn(3) {
+	case 0:
+		ladder.spacing = spacing{Orders: 2 + random.Intn(30), Geometric: random.Intn(2) == 0}
+	case 1:
+		ladder.spacing = spacing{Percent: roundTo(0.2+random.Float64()*5, 2)}
+	default:
+		intervals := 2 + random.Intn(30)
+		step := roundTo((ladder.priceMax-ladder.priceMin)/float64(intervals), ladder.rules.PricePrecision)
+		ladder.spacing = spacing{Step: math.Max(step, math.Pow10(-int(ladder.rules.PricePrecision)))}
+	}
+
+	levels := ladder.spacing.levelCount(ladder.priceMin, ladder.priceMax)
+	kinds := []string{FLAT_DISTRIBUTION, LINEAR_DISTRIBUTION, EXPONENTIAL_DISTRIBUTION, GEOMETRIC_DISTRIBUTION, CUSTOM_DISTRIBUTION}
+	ladder.dist = distribution{Kind: kinds[random.Intn(len(kinds))], Ratio: roundTo(0.5+random.Float64()*3.5, 2)}
+	if ladder.dist.Kind == CUSTOM_DISTRIBUTION {
+		for i := 0; i < levels; i++ {
+			ladder.dist.Weights = append(ladder.dist.Weights, roundTo(0.1+random.Float64()*10, 2))
+		}
+	}
+
+	// a few units of quote to a few thousands per level
+	quote := float64(levels) * math.Pow(10, random.Float64()*3.5)
+	if ladder.inQuote {
+		ladder.amount = roundTo(quote, 2)
+	} else {
+		ladder.amount = roundTo(quote/ladder.priceMin, ladder.rules.AmountPrecision)
+	}
+	return ladder
+}
+
+func (l randomLadder) plan() (Plan, error) {
+	currency := "ALPH"
+	if l.inQuote {
+		currency = "USDT"
+	}
+	return planLadder("ALPH_USDT", l.side, l.priceMin, l.priceMax, l.amount, currency, l.spacing, l.rules, l.dist)
+}
+
+// a base ladder places exactly the amount, a quote ladder spends at most the amount
+// and what it leaves could not buy one more unit at any level
+func checkPlanTotals(t *testing.T, l randomLadder, plan Plan) {
+	t.Helper()
+	requested := decimal.NewFromFloat(l.amount)
+	unit := amountUnit(l.rules)
+
+	if !l.inQuote {
+		if total := plan.totalBase(); !total.Equal(requested) {
+			t.Fatalf("%+v: places %s instead of %s", l, total, requested)
+		}
+		return
+	}
+
+	spent := plan.totalQuote()
+	if spent.GreaterThan(requested) {
+		t.Fatalf("%+v: spends %s, more than %s", l, spent, requested)
+	}
+	left := requested.Sub(spent)
+	for _, level := range plan.Levels {
+		if cost := level.Price.Mul(unit); left.GreaterThanOrEqual(cost) {
+			t.Fatalf("%+v: %s left, one more unit fits at %s", l, left, level.Price)
+		}
+	}
+}
+
+// every price on the tick grid and increasing, every amount on the amount grid and positive
+func checkPlanLevels(t *testing.T, l randomLadder, plan Plan) {
+	t.Helper()
+	for i, level := range plan.Levels {
+		if !level.Price.Equal(level.Price.Round(l.rules.PricePrecision)) {
+			t.Fatalf("%+v: price %s is finer than the price precision", l, level.Price)
+		}
+		if !level.Amount.Equal(level.Amount.RoundFloor(l.rules.AmountPrecision)) {
+			t.Fatalf("%+v: amount %s is finer than the amount precision", l, level.Amount)
+		}
+		if !level.Amount.IsPositive() {
+			t.Fatalf("%+v: level %d has no amount", l, i)
+		}
+		if i > 0 && !level.Price.GreaterThan(plan.Levels[i-1].Price) {
+			t.Fatalf("%+v: prices %s and %s are not increasing", l, plan.Levels[i-1].Price, level.Price)
+		}
+	}
+}
+
+func TestLadderLevelsProperties(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	planned := map[bool]int{}
+
+	for run := 0; run < LEVELS_PROPERTY_RUNS; run++ {
+		ladder := newRandomLadder(random)
+		plan, err := ladder.plan()
+		if isValidationError(err) {
+			// too small, too fine or too many levels for the pair, refused before anything is sent
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%+v: %s", ladder, err)
+		}
+
+		checkPlanLevels(t, ladder, plan)
+		checkPlanTotals(t, ladder, plan)
+		planned[ladder.inQuote]++
+	}
+
+	// the refused ladders must not hide the property
+	if planned[false] < LEVELS_PROPERTY_RUNS/10 || planned[true] < LEVELS_PROPERTY_RUNS/10 {
+		t.Fatalf("too few ladders planned: %d in base, %d in quote", planned[false], planned[true])
+	}
+}
+
+func TestStopLimitOrdersProperties(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	checked := 0
+
+	for run := 0; run < LEVELS_PROPERTY_RUNS; run++ {
+		ladder := newRandomLadder(random)
+		plan, err := ladder.plan()
+		if err != nil || plan.checkTriggers() != nil {
+			continue
+		}
+
+		limits := plan.limitOrders("gtc")
+		stops := plan.stopLimitOrders()
+		if len(stops) != len(limits) {
+			t.Fatalf("%+v: %d stop-limit orders for %d limit orders", ladder, len(stops), len(limits))
+		}
+		for i, stop := range stops {
+			// the same levels whichever order type sends them
+			if stop.Put.Price != limits[i].Price || stop.Put.Amount != limits[i].Amount || stop.Put.Side != ladder.side {
+				t.Fatalf("%+v: stop-limit level %d is %s at %s, the limit level is %s at %s", ladder, i, stop.Put.Amount, stop.Put.Price, limits[i].Amount, limits[i].Price)
+			}
+
+			price := decimal.RequireFromString(stop.Put.Price)
+			trigger := decimal.RequireFromString(stop.Trigger.Price)
+			// a buy fires when the price rises to a trigger under its limit, a sell when it falls to one above
+			if !trigger.IsPositive() || (ladder.side == buy && !trigger.LessThan(price)) || (ladder.side == sell && !trigger.GreaterThan(price)) {
+				t.Fatalf("%+v: trigger %s for the %s level at %s", ladder, trigger, ladder.side, price)
+			}
+			if !trigger.Equal(trigger.Round(ladder.rules.PricePrecision)) {
+				t.Fatalf("%+v: trigger %s is finer than the price precision", ladder, trigger)
+			}
+		}
+		checkPlanTotals(t, ladder, plan)
+		checked++
+	}
+
+	if checked < LEVELS_PROPERTY_RUNS/5 {
+		t.Fatalf("too few stop-limit ladders checked: %d", checked)
+	}
+}
@@ -1,20 +1,15 @@
 package main
 
 import (
-	"bufio"
-	"context"
 	"flag"
 	"fmt"
-	"log"
 	"math/rand"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/antihax/optional"
 	"github.com/gateio/gateapi-go/v6"
-	"github.com/joho/godotenv"
 )
 
 var seededRand *rand.Rand = rand.New(
@@ -22,6 +17,7 @@ var seededRand *rand.Rand = rand.New(
 
 const GATE_MAX_SIZE_BATCH int = 10
 const DEFAULT_STEPS float64 = 0.005
+const DEFAULT_PAIR string = "ALPH_USDT"
 
 const (
 	GOOD_TILL_CANCEL    = "gtc"
@@ -30,9 +26,16 @@ const (
 
 var priceMin float64
 var priceMax float64
-var amountUSDT float64
-var amountAlph float64
+var pair string
+var baseCurrency string
+var quoteCurrency string
+var amountQuote float64
+var amountBase float64
 var steps float64
+var stepsPercent float64
+var numOrders int
+var geometric bool
+var ladderSpacing spacing
 var side string
 var listOpenOrders bool
 var timeInForce string
@@ -41,6 +44,23 @@ var listPastOrders bool
 var limit int64
 var lastDays int
 
+var distributionKind string
+var distributionRatio float64
+var distributionWeights string
+var ladderDistribution distribution
+
+var dryRun bool
+var dryRunPrice float64
+
+var exchangeName string
+var simPrice float64
+var simBalances string
+var simPath string
+
+var command string
+
+var accountName string
+
 var gateioKey string
 var gateioSecret string
 
@@ -51,11 +71,22 @@ func getParams() {
 
 	flag.StringVar(&side, "side", "", "buy or sell")
 
-	flag.Float64Var(&amountUSDT, "amountUsdt", 0.0, "Set the total amount in USDT")
+	flag.StringVar(&pair, "pair", DEFAULT_PAIR, "Currency pair to trade, BASE_QUOTE")
 
-	flag.Float64Var(&amountAlph, "amountAlph", 0.0, "Set the total amount in USDT")
+	flag.Float64Var(&amountQuote, "amountQuote", 0.0, "Set the total amount in quote currency")
+	flag.Float64Var(&amountQuote, "amountUsdt", 0.0, "Alias of amountQuote")
+
+	flag.Float64Var(&amountBase, "amountBase", 0.0, "Set the total amount in base currency")
+	flag.Float64Var(&amountBase, "amountAlph", 0.0, "Alias of amountBase")
 
 	flag.Float64Var(&steps, "steps", DEFAULT_STEPS, "Set the steps between the prices")
+	flag.Float64Var(&stepsPercent, "stepspercent", 0.0, "Set the steps between the prices in percent, prices grow geometrically")
+	flag.IntVar(&numOrders, "orders", 0, "Set the number of orders instead of the steps")
+	flag.BoolVar(&geometric, "geometric", false, "Space the prices geometrically when using -orders")
+
+	flag.StringVar(&distributionKind, "distribution", FLAT_DISTRIBUTION, "Spread of the amount over the levels: flat, linear, exponential, geometric or custom")
+	flag.Float64Var(&distributionRatio, "ratio", DEFAULT_DISTRIBUTION_RATIO, "Heaviest to lightest level ratio (linear, exponential), or ratio between two levels (geometric)")
+	flag.StringVar(&distributionWeights, "weights", "", "Comma separated weights from the lowest to the highest price, for the custom distribution")
 
 	flag.BoolVar(&listOpenOrders, "listopen", false, "List open orders")
 
@@ -67,13 +98,166 @@ func getParams() {
 
 	flag.BoolVar(&useSl, "sl", false, "Use Stop-Limit instead of Limit")
 
-	flag.Parse()
+	flag.BoolVar(&dryRun, "dry-run", false, "Print the ladder and exit, no api key needed")
+	flag.Float64Var(&dryRunPrice, "price", 0.0, "Reference price for dry-run, defaults to the last cached or live price")
+
+	flag.StringVar(&exchangeName, "exchange", GATEIO_EXCHANGE, "Exchange backend, gateio or sim (in-memory simulator)")
+	flag.Float64Var(&simPrice, "simprice", 0.0, "Starting price of the simulator, defaults to the ladder edge")
+	flag.StringVar(&simBalances, "simbalance", "", "Starting balances of the simulator, e.g. USDT=1000,ALPH=500")
+	flag.StringVar(&simPath, "simpath", "", "grid: prices the simulator moves through, one per poll, e.g. 0.40,0.38,0.41")
+
+	flag.BoolVar(&assumeYes, "yes", false, "Answer yes to the confirmations, to run without a terminal")
+	flag.StringVar(&onCross, "on-cross", "", "When the price already crossed the ladder: continue, stop-limit or abort, asked if not set")
+	flag.Float64Var(&maxTakerExposure, "max-taker", 0.0, "Largest quote amount the ladder may fill immediately against the order book, 0 for no limit")
+	flag.BoolVar(&allowExistingOrders, "allow-existing-orders", false, "Place the ladder even if orders are already open on the pair")
+
+	flag.StringVar(&outputFormat, "output", TABLE_OUTPUT, "Output of list, listopen, dry-run and pnl: table, json or csv")
+
+	flag.StringVar(&wsUrl, "wsurl", GATE_WS_URL, "watch: websocket url")
+
+	flag.DurationVar(&gridInterval, "interval", DEFAULT_GRID_INTERVAL, "grid: delay between two checks of the orders")
+
+	flag.StringVar(&pnlCost, "cost", FIFO_COST, "pnl: cost accounting, fifo or average")
+	flag.StringVar(&pnlFromDate, "from", "", "pnl: first day of the range (YYYY-MM-DD), defaults to -lastdays or 30 days before -to")
+	flag.StringVar(&pnlToDate, "to", "", "pnl: last day of the range (YYYY-MM-DD), defaults to today")
+
+	flag.StringVar(&configPath, "config", "", "Configuration file, defaults to $XDG_CONFIG_HOME/steps-bot/config.yaml then $XDG_CONFIG_DIRS")
+	flag.StringVar(&profileName, "profile", "", "Profile of the configuration to use, defaults to the profile set in the configuration")
+	flag.StringVar(&presetName, "preset", "", "Strategy preset of the configuration applied over the profile")
+	flag.StringVar(&accountName, "account", "", "Account whose api keys are read from GATEIO_KEY_<ACCOUNT> and GATEIO_SECRET_<ACCOUNT>")
+	flag.StringVar(&keyCommand, "key-cmd", "", "Command printing the api key then the secret on two lines, e.g. \"pass show gate\"")
+	flag.IntVar(&keyFd, "key-fd", 0, "File descriptor to read the api key then the secret from, on two lines")
+	flag.StringVar(&splitAccounts, "split-accounts", "", "Comma separated accounts sharing the ladder in proportion to their balance, or all")
+
+	flag.IntVar(&ladderId, "ladder", 0, "list, listopen, cancel, journal, resume, pnl: only orders of this ladder id")
+	flag.StringVar(&cancelTag, "tag", "", "cancel: only orders whose text starts with this tag")
+
+	// an optional command comes before the flags
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command = args[0]
+		args = args[1:]
+	}
+	// keys takes an action and an account before the flags
+	for command == KEYS_COMMAND && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		keysArgs = append(keysArgs, args[0])
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
+
+	loadDotEnv()
+	exitOnError(applyConfig())
 	checkArgs()
 }
 
 func checkArgs() {
 	error := false
 
+	base, quote, ok := splitPair(pair)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "pair must be formatted as BASE_QUOTE\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+	pair = base + "_" + quote
+	baseCurrency = base
+	quoteCurrency = quote
+
+	if exchangeName != GATEIO_EXCHANGE && exchangeName != SIMULATOR_EXCHANGE {
+		fmt.Fprintf(os.Stderr, "Exchange accepted value. gateio or sim\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if outputFormat != TABLE_OUTPUT && outputFormat != JSON_OUTPUT && outputFormat != CSV_OUTPUT {
+		fmt.Fprintf(os.Stderr, "Output accepted value. table, json or csv\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if outputFormat != TABLE_OUTPUT && !listOpenOrders && !listPastOrders && !dryRun && command != PNL_COMMAND {
+		fmt.Fprintf(os.Stderr, "json and csv output are only available for list, listopen, dry-run and pnl\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if !checkPromptArgs() {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if command != "" && command != CANCEL_COMMAND && command != LADDERS_COMMAND && command != JOURNAL_COMMAND && command != RESUME_COMMAND && command != GRID_COMMAND && command != WATCH_COMMAND && command != PNL_COMMAND && command != ACCOUNTS_COMMAND && command != KEYS_COMMAND {
+		fmt.Fprintf(os.Stderr, "Unknown command %s\n", command)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if command == CANCEL_COMMAND {
+		checkCancelArgs()
+		return
+	}
+
+	if command == LADDERS_COMMAND || command == JOURNAL_COMMAND || command == ACCOUNTS_COMMAND {
+		return
+	}
+
+	if command == KEYS_COMMAND {
+		checkKeysArgs()
+		return
+	}
+
+	if keyCommand != "" && keyFd > 0 {
+		fmt.Fprintf(os.Stderr, "Use only one of key-cmd or key-fd\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if splitAccounts != "" && keyFd > 0 {
+		fmt.Fprintf(os.Stderr, "key-fd holds the keys of one account, use key-cmd with split-accounts\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if splitAccounts != "" && (command != "" || listOpenOrders || listPastOrders || accountName != "") {
+		fmt.Fprintf(os.Stderr, "split-accounts only places a ladder and cannot be used with account\n")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if command == WATCH_COMMAND {
+		if exchangeName != GATEIO_EXCHANGE {
+			fmt.Fprintf(os.Stderr, "watch only works with the gateio exchange\n")
+			flag.Usage()
+			os.Exit(1)
+		}
+		return
+	}
+
+	if command == RESUME_COMMAND {
+		checkResumeArgs()
+		return
+	}
+
+	if command == PNL_COMMAND {
+		checkPnlArgs()
+		return
+	}
+
+	// a grid is resumed from its saved state
+	if command == GRID_COMMAND && ladderId > 0 {
+		return
+	}
+
+	if command == GRID_COMMAND && useSl {
+		fmt.Fprintf(os.Stderr, "Grid only uses limit orders\n")
+		error = true
+	}
+
+	if command == GRID_COMMAND && gridInterval <= 0 {
+		fmt.Fprintf(os.Stderr, "Interval must be positive\n")
+		error = true
+	}
+
 	if listOpenOrders || listPastOrders {
 		return
 	}
@@ -93,12 +277,12 @@ func checkArgs() {
 		error = true
 	}
 
-	if amountUSDT <= 0.0 && amountAlph <= 0.0 {
+	if amountQuote <= 0.0 && amountBase <= 0.0 {
 		fmt.Fprintf(os.Stderr, "Amount is mandatory\n")
 		error = true
 	}
 
-	if amountUSDT > 0.0 && amountAlph > 0.0 {
+	if amountQuote > 0.0 && amountBase > 0.0 {
 		fmt.Fprintf(os.Stderr, "Cannot mix amount, select only one\n")
 		error = true
 	}
@@ -108,6 +292,50 @@ func checkArgs() {
 		error = true
 	}
 
+	stepsSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "steps" {
+			stepsSet = true
+		}
+	})
+
+	if stepsSet && steps <= 0.0 {
+		fmt.Fprintf(os.Stderr, "Steps must be positive\n")
+		error = true
+	}
+
+	if stepsPercent < 0.0 || numOrders < 0 {
+		fmt.Fprintf(os.Stderr, "Steps percent and orders must be positive\n")
+		error = true
+	}
+
+	if (stepsSet && stepsPercent > 0.0) || (numOrders > 0 && (stepsSet || stepsPercent > 0.0)) {
+		fmt.Fprintf(os.Stderr, "Use only one of steps, stepspercent or orders\n")
+		error = true
+	}
+
+	ladderSpacing = spacing{Step: steps, Percent: stepsPercent, Orders: numOrders, Geometric: geometric}
+
+	if !isDistribution(distributionKind) {
+		fmt.Fprintf(os.Stderr, "Distribution accepted value. flat, linear, exponential, geometric or custom\n")
+		error = true
+	}
+
+	if distributionRatio <= 0.0 {
+		fmt.Fprintf(os.Stderr, "Ratio must be positive\n")
+		error = true
+	}
+
+	ladderDistribution = distribution{Kind: distributionKind, Ratio: distributionRatio}
+	if distributionKind == CUSTOM_DISTRIBUTION {
+		weights, err := parseWeights(distributionWeights)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Custom distribution needs -weights: %s\n", err)
+			error = true
+		}
+		ladderDistribution.Weights = weights
+	}
+
 	if timeInForce != GOOD_TILL_CANCEL && timeInForce != IMMEDIATE_OR_CANCEL {
 		fmt.Fprintf(os.Stderr, "Time in force accepted value. gtc or ioc\n")
 		error = true
@@ -120,65 +348,70 @@ func checkArgs() {
 
 }
 func getEnv() {
-	err := godotenv.Load(".env")
+	var err error
+	gateioKey, gateioSecret, err = accountCredentials(accountName)
 	if err != nil {
-		log.Fatalf("Some error occured. Err: %s", err)
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		flag.Usage()
+		os.Exit(1)
 	}
+}
 
-	error := false
-	gateioKey = os.Getenv("GATEIO_KEY")
-	if gateioKey == "" {
-		fmt.Fprintf(os.Stderr, "Gate.io api key is missing\n")
-		error = true
-
+// print the error and exit, validation errors are shown as plain messages
+func exitOnError(err error) {
+	if err == nil {
+		return
 	}
-
-	gateioSecret = os.Getenv("GATEIO_SECRET")
-	if gateioSecret == "" {
-		fmt.Fprintf(os.Stderr, "Gate.io secret key is missing\n")
-		error = true
-
+	if isValidationError(err) {
+		fmt.Fprintf(os.Stderr, "\n%s\n", err)
+	} else {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
 	}
+	os.Exit(1)
+}
 
-	if error {
-		flag.Usage()
+// refuse a plan the balance of the spent currency does not cover, where names the account when there are several
+func checkPlanBalance(exchange Exchange, plan Plan, where string) {
+	currency, needed := plan.spending()
+	balanceOk, balance, err := balanceEnough(exchange, currency, needed)
+	exitOnError(err)
+	if !balanceOk {
+		fmt.Fprintf(os.Stderr, "\nNot enough %s%s, actual balance: %.4f needed: %.4f\n", currency, where, balance, needed)
 		os.Exit(1)
 	}
-
 }
 
-func balanceEnough(client *gateapi.APIClient, ctx *context.Context, currency string, amount float64) (bool, float64) {
+func balanceEnough(exchange Exchange, currency string, amount float64) (bool, float64, error) {
 
-	allBalances := checkBalance(client, ctx)
+	allBalances, err := checkBalance(exchange)
+	if err != nil {
+		return false, 0.0, err
+	}
 
 	for balanceIndex := 0; balanceIndex < len(allBalances); balanceIndex++ {
 		if strings.ToUpper(allBalances[balanceIndex].Currency) == currency {
 
-			available, err := strconv.ParseFloat(allBalances[balanceIndex].Available, 64)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Formatting not possible for available balance: %s\n", err)
-				panic(err)
-			}
-
-			locked, _ := strconv.ParseFloat(allBalances[balanceIndex].Locked, 64)
+			// the available balance already excludes what open orders lock
+			available, err := parseFloatField("available balance", allBalances[balanceIndex].Available)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Formatting not possible for locked balance: %s\n", err)
-				panic(err)
+				return false, 0.0, err
 			}
 
-			if available-locked >= amount {
-				return true, available - locked
-			}
-			return false, available - locked
+			return available >= amount, available, nil
 		}
 	}
 
-	return false, 0.0
+	return false, 0.0, nil
 
 }
 
-func printOpenOrders(client *gateapi.APIClient, ctx *context.Context) {
-	openOrders := getOpenOrders(client, ctx, "ALPH_USDT")
+func printOpenOrders(exchange Exchange, pair string) error {
+	base, quote, _ := splitPair(pair)
+	openOrders, err := getOpenOrders(exchange, pair)
+	if err != nil {
+		return err
+	}
+	openOrders = filterLadderOrders(openOrders)
 
 	var buyOrders []gateapi.Order
 	var sellOrders []gateapi.Order
@@ -197,22 +430,22 @@ func printOpenOrders(client *gateapi.APIClient, ctx *context.Context) {
 	for i := 0; i < len(buyOrders); i++ {
 		order := buyOrders[i]
 
-		amount, err := strconv.ParseFloat(order.Amount, 64)
+		amount, err := parseFloatField("order amount", order.Amount)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Formatting not possible for formatOpenOrders: %s\n", err)
-			panic(err)
+			return err
 		}
 
-		price, err := strconv.ParseFloat(order.Price, 64)
+		price, err := parseFloatField("order price", order.Price)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Formatting not possible for formatOpenOrders: %s\n", err)
-			panic(err)
+			return err
 		}
 		amountFiat += price * amount
 		amountCrypto += amount
-		formatOpenOrders(&order)
+		if err := formatOpenOrders(&order); err != nil {
+			return err
+		}
 	}
-	fmt.Printf("Total: +%.3f ALPH | -%.3f USDT\n", amountCrypto, amountFiat)
+	fmt.Printf("Total: +%.3f %s | -%.3f %s\n", amountCrypto, base, amountFiat, quote)
 
 	amountFiat = 0.0
 	amountCrypto = 0.0
@@ -221,52 +454,68 @@ func printOpenOrders(client *gateapi.APIClient, ctx *context.Context) {
 
 		order := sellOrders[i]
 
-		amount, err := strconv.ParseFloat(order.Amount, 64)
+		amount, err := parseFloatField("order amount", order.Amount)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Formatting not possible for formatOpenOrders: %s\n", err)
-			panic(err)
+			return err
 		}
 
-		price, err := strconv.ParseFloat(order.Price, 64)
+		price, err := parseFloatField("order price", order.Price)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Formatting not possible for formatOpenOrders: %s\n", err)
-			panic(err)
+			return err
 		}
 
-		formatOpenOrders(&order)
+		if err := formatOpenOrders(&order); err != nil {
+			return err
+		}
 
 		amountFiat += price * amount
 		amountCrypto += amount
 
 	}
-	fmt.Printf("Total: -%.3f ALPH | +%.3f USDT", amountCrypto, amountFiat)
-
-}
-
-func printFilledOrders(client *gateapi.APIClient, ctx *context.Context, side string, limit int32) {
-	var options gateapi.ListOrdersOpts
+	fmt.Printf("Total: -%.3f %s | +%.3f %s\n", amountCrypto, base, amountFiat, quote)
 
-	if limit > 0 {
-		//side is only compatible with limit
-		options = gateapi.ListOrdersOpts{
-			Limit: optional.NewInt32(limit),
-			Side:  optional.NewString(side),
-		}
+	triggered, err := getTriggeredOrders(exchange, pair, "open")
+	if err != nil {
+		return err
+	}
+	triggered, err = filterLadderTriggeredOrders(triggered)
+	if err != nil {
+		return err
 	}
+	if len(triggered) > 0 {
+		fmt.Println("Stop-limit open orders")
+	}
+	for _, order := range triggered {
+		fmt.Printf("%s when price %s %s %s: price: %s %s, amount: %s\n", order.Put.Side, order.Trigger.Rule, order.Trigger.Price, quote, order.Put.Price, quote, order.Put.Amount)
+	}
+
+	return nil
+}
 
+// finished orders of the last days, or the last limit orders of the side
+func listFilledOrders(exchange Exchange, pair string, side string, limit int32) ([]gateapi.Order, error) {
+	var orders []gateapi.Order
+	var err error
 	if lastDays > 0 {
-		options = gateapi.ListOrdersOpts{
-			Limit: optional.NewInt32(1000),
-			To:    optional.NewInt64(time.Now().Unix()),
-			From:  optional.NewInt64(time.Now().Unix() - int64(lastDays*86400)),
-		}
+		now := time.Now().Unix()
+		orders, err = getFinishedOrders(exchange, pair, "", now-int64(lastDays*ONE_DAY_SEC), now, 0)
+	} else {
+		orders, err = getFinishedOrders(exchange, pair, side, 0, 0, int(limit))
 	}
+	if err != nil {
+		return nil, err
+	}
+	return filterLadderOrders(orders), nil
+}
 
-	var orders []gateapi.Order
-	orders = getOrders(client, ctx, "ALPH_USDT", "finished", &options)
+func printFilledOrders(exchange Exchange, pair string, side string, limit int32) error {
+	base, quote, _ := splitPair(pair)
+
+	orders, err := listFilledOrders(exchange, pair, side, limit)
+	if err != nil {
+		return err
+	}
 
-	orderFilledCounter := 0
-	avgPrice := 0.0
 	var priceArray []float64
 	totalToken := 0.0
 	totalFiat := 0.0
@@ -278,151 +527,341 @@ func printFilledOrders(client *gateapi.APIClient, ctx *context.Context, side str
 		filledTotal, _ := strconv.ParseFloat(order.FilledTotal, 64)
 		if order.AvgDealPrice != "" && filledTotal > 0.0 && order.Side == side {
 
-			orderFilledCounter++
 			orderAvgPrice, _ := strconv.ParseFloat(order.AvgDealPrice, 64)
 
-			orderAmount, _ := strconv.ParseFloat(order.Amount, 64)
+			// only the filled part of the order counts
+			amount, _ := strconv.ParseFloat(order.Amount, 64)
+			left, _ := strconv.ParseFloat(order.Left, 64)
+			orderAmount := amount - left
 
-			avgPrice += orderAvgPrice
 			totalToken += orderAmount
 			totalFiat += filledTotal
 			priceArray = append(priceArray, orderAvgPrice)
-			fmt.Printf("avg filled price: %s USDT, %.4f USDT, Volume: %.4f ALPH (created at: %s)\n", order.AvgDealPrice, filledTotal, orderAmount, time.Unix(order.CreateTimeMs/1000, 0))
+			fmt.Printf("avg filled price: %s %s, %.4f %s, Volume: %.4f %s (created at: %s)\n", order.AvgDealPrice, quote, filledTotal, quote, orderAmount, base, time.Unix(order.CreateTimeMs/1000, 0))
 		}
 	}
 
-	if len(orders) > 0 {
-		operatorAlph := "-"
-		operatorUsdt := "+"
+	if totalToken > 0 {
+		operatorBase := "-"
+		operatorQuote := "+"
 		if side == buy {
-			operatorAlph = "+"
-			operatorUsdt = "-"
+			operatorBase = "+"
+			operatorQuote = "-"
 		}
-		fmt.Printf("Avg paid price: %.4f USDT, median: %.4f USDT, Total: %s%.4f ALPH, Total: %s%.4f USDT\n\n", avgPrice/float64(orderFilledCounter), median(priceArray), operatorAlph, totalToken, operatorUsdt, totalFiat)
+		fmt.Printf("Avg paid price: %.4f %s, median: %.4f %s, Total: %s%.4f %s, Total: %s%.4f %s\n\n", totalFiat/totalToken, quote, median(priceArray), quote, operatorBase, totalToken, base, operatorQuote, totalFiat, quote)
 	}
 
+	return nil
+}
+
+// export the filled orders of both sides, buys first
+func exportFilledOrders(exchange Exchange, pair string, limit int32) error {
+	table := newExportTable("id", "text", "side", "price", "avg_deal_price", "amount", "filled_amount", "filled_total", "fee", "fee_currency", "status", "finish_as", "create_time")
+	for _, orderSide := range []string{buy, sell} {
+		orders, err := listFilledOrders(exchange, pair, orderSide, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, order := range orders {
+			filledTotal, _ := strconv.ParseFloat(order.FilledTotal, 64)
+			if order.Side != orderSide || filledTotal <= 0 {
+				continue
+			}
+			amount, _ := strconv.ParseFloat(order.Amount, 64)
+			left, _ := strconv.ParseFloat(order.Left, 64)
+			table.add(order.Id, order.Text, order.Side, order.Price, order.AvgDealPrice, order.Amount, decimalString(amount-left), order.FilledTotal, order.Fee, order.FeeCurrency, order.Status, order.FinishAs, time.UnixMilli(order.CreateTimeMs).UTC().Format(time.RFC3339))
+		}
+	}
+	return writeTable(table)
+}
+
+// export the open limit and stop-limit orders, only those of -ladder when given
+func exportOpenOrders(exchange Exchange, pair string) error {
+	openOrders, err := getOpenOrders(exchange, pair)
+	if err != nil {
+		return err
+	}
+
+	table := newExportTable("id", "text", "type", "side", "price", "trigger_price", "amount", "left", "filled_total", "create_time")
+	for _, order := range filterLadderOrders(openOrders) {
+		table.add(order.Id, order.Text, "limit", order.Side, order.Price, "", order.Amount, order.Left, order.FilledTotal, time.UnixMilli(order.CreateTimeMs).UTC().Format(time.RFC3339))
+	}
+
+	triggered, err := getTriggeredOrders(exchange, pair, "open")
+	if err != nil {
+		return err
+	}
+	triggered, err = filterLadderTriggeredOrders(triggered)
+	if err != nil {
+		return err
+	}
+	for _, order := range triggered {
+		table.add(strconv.FormatInt(order.Id, 10), "", "stop-limit", order.Put.Side, order.Put.Price, order.Trigger.Price, order.Put.Amount, order.Put.Amount, "0", time.Unix(order.Ctime, 0).UTC().Format(time.RFC3339))
+	}
+	return writeTable(table)
 }
 
 func main() {
 
 	getParams()
-	getEnv()
-
-	client := gateapi.NewAPIClient(gateapi.NewConfiguration())
-	// uncomment the next line if your are testing against testnet
-	// client.ChangeBasePath("https://fx-api-testnet.gateio.ws/api/v4")
-	ctx := context.WithValue(context.Background(),
-		gateapi.ContextGateAPIV4,
-		gateapi.GateAPIV4{
-			Key:    gateioKey,
-			Secret: gateioSecret,
-		},
-	)
+	if outputFormat != TABLE_OUTPUT {
+		humanOutput = os.Stderr
+	}
+
+	if dryRun {
+		runDryRun()
+		os.Exit(0)
+	}
+	if command == LADDERS_COMMAND {
+		printLadders()
+		os.Exit(0)
+	}
+	if command == JOURNAL_COMMAND {
+		printJournal()
+		os.Exit(0)
+	}
+	if command == WATCH_COMMAND {
+		getEnv()
+		runWatch()
+		os.Exit(0)
+	}
+	if command == ACCOUNTS_COMMAND {
+		printAccounts()
+		os.Exit(0)
+	}
+	if command == KEYS_COMMAND {
+		runKeys()
+		os.Exit(0)
+	}
+	if splitAccounts != "" {
+		runSplitLadder()
+		os.Exit(0)
+	}
+
+	if command == RESUME_COMMAND || (command == GRID_COMMAND && ladderId > 0) {
+		exitOnError(useLadderAccount(ladderId))
+	}
+
+	var exchange Exchange
+	var advance func() bool
+	if exchangeName == SIMULATOR_EXCHANGE {
+		sim := newSimulatorFromFlags()
+		if simPath != "" {
+			advance = sim.Advance
+		}
+		exchange = sim
+	} else {
+		getEnv()
+		exchange = newRetryExchange(newGateioExchange(gateioKey, gateioSecret))
+	}
+	journal := newJournalExchange(exchange)
+	exchange = journal
+
 	// check if connected correctly
-	getAccountDetails(client, &ctx)
+	account, err := getAccountDetails(exchange)
+	exitOnError(err)
+	fmt.Fprintf(humanOutput, "%+v\n", account)
 
+	if listPastOrders && outputFormat != TABLE_OUTPUT {
+		exitOnError(exportFilledOrders(exchange, pair, int32(limit)))
+		os.Exit(0)
+	}
+	if listOpenOrders && outputFormat != TABLE_OUTPUT {
+		exitOnError(exportOpenOrders(exchange, pair))
+		os.Exit(0)
+	}
 	if listPastOrders {
-		printFilledOrders(client, &ctx, buy, int32(limit))
-		printFilledOrders(client, &ctx, sell, int32(limit))
+		exitOnError(printFilledOrders(exchange, pair, buy, int32(limit)))
+		exitOnError(printFilledOrders(exchange, pair, sell, int32(limit)))
 		os.Exit(0)
 	}
 	if listOpenOrders {
-		printOpenOrders(client, &ctx)
+		exitOnError(printOpenOrders(exchange, pair))
+		os.Exit(0)
+	}
+	if command == CANCEL_COMMAND {
+		runCancel(exchange)
+		os.Exit(0)
+	}
+	if command == RESUME_COMMAND {
+		journal.ladder = ladderId
+		runResume(exchange)
+		os.Exit(0)
+	}
+	if command == GRID_COMMAND {
+		runGrid(exchange, journal, advance)
+		os.Exit(0)
+	}
+	if command == PNL_COMMAND {
+		runPnl(exchange)
 		os.Exit(0)
 	}
 
-	currentPrice := getTickerPrice(client, &ctx, "ALPH_USDT")
-	useTriggeredOrder := false
+	rules, err := getPairRules(exchange, pair)
+	exitOnError(err)
+	currentPrice, err := getTickerPrice(exchange, pair)
+	exitOnError(err)
+	if exchangeName == GATEIO_EXCHANGE {
+		if err := saveMarketCache(pair, currentPrice, rules); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot cache market data: %s\n", err)
+		}
+	}
+
+	checkExistingOrders(exchange, "")
 
-	if (side == buy && priceMin >= currentPrice) || (side == sell && priceMin <= currentPrice) && !useSl {
+	fmt.Printf("Here are the orders you gonna create\n")
+	var orders []gateapi.Order
+	var sLOrders []gateapi.SpotPriceTriggeredOrder
+	// specify the ticker we are going to use to buy or sell and the amount in order
+	ticker, amount := ladderAmount()
+
+	// the levels are the same whichever order type sends them
+	plan, err := planLadder(pair, side, priceMin, priceMax, amount, ticker, ladderSpacing, rules, ladderDistribution)
+	exitOnError(err)
+	checkPlanBalance(exchange, plan, "")
 
-		fmt.Printf("\nActual price is %.4f USDT, your %s orders will start at %.4f.\nIf you continue, the orders are going to be filled immediately\n1) Continue\n2) Use Stop-Limit orders\n3) Cancel\nChoice: ", currentPrice, side, priceMin)
+	useTriggeredOrder := useSl
+	if !useTriggeredOrder {
+		fmt.Printf("Using limit orders\n")
+		plan.print(LIMIT_ORDER)
+		orders = plan.limitOrders(timeInForce)
 
-		input := bufio.NewScanner(os.Stdin)
-		input.Scan()
-		choice := strings.ToLower(input.Text())
+		// levels crossing the book are filled immediately as taker
+		report, err := checkRisk(exchange, pair, orders)
+		exitOnError(err)
+		printRiskReport(report, side, rules)
 
-		if choice == "1" {
-			useTriggeredOrder = false
-		} else if choice == "2" {
+		if len(report.crossing()) > 0 && chooseOnCross(report, currentPrice) {
 			useTriggeredOrder = true
-		} else if choice == "3" {
-			os.Exit(0)
-		} else {
-			os.Exit(0)
+			orders = nil
 		}
-		fmt.Println()
+	}
+
+	if useTriggeredOrder {
+		fmt.Printf("Using Stop-limit orders\n")
+		exitOnError(plan.checkTriggers())
+		plan.print(STOP_LIMIT_ORDER)
+		sLOrders = plan.stopLimitOrders()
+
+		// levels whose trigger is already reached reach the book as soon as they are placed
+		report, err := checkStopLimitRisk(exchange, pair, sLOrders, currentPrice)
+		exitOnError(err)
+		printRiskReport(report, side, rules)
+		exitOnError(checkTakerExposure(report))
+	}
 
+	fmt.Println()
+	if !confirm("Do you want to continue?") {
+		os.Exit(0)
 	}
-	useTriggeredOrder = useSl
 
-	if checkOrdersOpen(client, &ctx, "ALPH_USDT") {
-		fmt.Printf("Some orders are already open\nDo you want to continue? [y/N] ")
-		input := bufio.NewScanner(os.Stdin)
-		input.Scan()
+	fmt.Printf("\n")
+
+	ok := placeLadder(exchange, journal, ladderRecord{
+		Exchange:       exchangeName,
+		Account:        accountName,
+		Pair:           pair,
+		Side:           side,
+		PriceMin:       priceMin,
+		PriceMax:       priceMax,
+		Amount:         amount,
+		AmountCurrency: ticker,
+		Spacing:        ladderSpacing,
+		Distribution:   ladderDistribution,
+		TimeInForce:    timeInForce,
+		StopLimit:      useTriggeredOrder,
+	}, orders, sLOrders)
+	if !ok {
+		os.Exit(1)
+	}
+}
 
-		if strings.ToLower(input.Text()) != "y" {
+// ask before adding a ladder next to open orders, where names the account when there are several
+func checkExistingOrders(exchange Exchange, where string) {
+	ordersOpen, err := checkOrdersOpen(exchange, pair)
+	exitOnError(err)
+	if ordersOpen && allowExistingOrders {
+		fmt.Printf("Some orders are already open%s, continuing (-allow-existing-orders)\n\n", where)
+	} else if ordersOpen {
+		fmt.Printf("Some orders are already open%s\n", where)
+		requireTerminal("-allow-existing-orders")
+		fmt.Printf("Do you want to continue? [y/N] ")
+		if readAnswer() != "y" {
 			os.Exit(0)
 		}
 		fmt.Println()
 
 	}
+}
 
-	fmt.Printf("Here are the orders you gonna create\n")
-	var orders []gateapi.Order
-	var sLOrders []gateapi.SpotPriceTriggeredOrder
-	var ticker string  // specify the ticker we are going to use to buy or sell
-	var amount float64 // amount in order
-
-	if amountUSDT > 0.0 {
-		ticker = "USDT"
-		amount = amountUSDT
-	} else if amountAlph > 0.0 {
-		ticker = "ALPH"
-		amount = amountAlph
+// ask what to do with a limit ladder crossing the book, true to send it as stop-limit orders instead
+func chooseOnCross(report riskReport, currentPrice float64) bool {
+	fmt.Printf("\nActual price is %.4f %s, %d of your %s orders cross the order book.\nIf you continue, they are going to be filled immediately\n1) Continue\n2) Use Stop-Limit orders\n3) Cancel\nChoice: ", currentPrice, quoteCurrency, len(report.crossing()), side)
+
+	var choice string
+	switch onCross {
+	case ON_CROSS_CONTINUE:
+		choice = "1"
+	case ON_CROSS_STOP_LIMIT:
+		choice = "2"
+	case ON_CROSS_ABORT:
+		choice = "3"
+	default:
+		requireTerminal("-on-cross")
+		choice = readAnswer()
 	}
-
-	balanceOk, balance := balanceEnough(client, &ctx, ticker, amount)
-	if !balanceOk {
-		fmt.Fprintf(os.Stderr, "\nNot enough %s, actual balance: %2.f needed: %.2f\n", ticker, balance, amount)
-		os.Exit(1)
+	if onCross != "" {
+		fmt.Printf("%s (-on-cross %s)\n", choice, onCross)
 	}
 
-	if !useTriggeredOrder {
-		fmt.Printf("Using limit orders\n")
-		orders = selectFiatOrCrypto(ticker, "ALPH_USDT", side, priceMin, priceMax, amount, steps, timeInForce)
-	} else {
-		fmt.Printf("Using Stop-limit orders\n")
-		sLOrders = selectFiatOrCryptoTriggered(ticker, "ALPH_USDT", side, priceMin, priceMax, amount, steps)
+	if choice == "1" {
+		exitOnError(checkTakerExposure(report))
+		fmt.Println()
+		return false
 	}
-
-	fmt.Printf("\nDo you want to continue? [y/N] ")
-	input := bufio.NewScanner(os.Stdin)
-	input.Scan()
-
-	if strings.ToLower(input.Text()) != "y" {
-		os.Exit(0)
+	if choice == "2" {
+		fmt.Println()
+		return true
 	}
+	os.Exit(0)
+	return false
+}
 
-	fmt.Printf("\n")
-
-	if !useTriggeredOrder {
-		for i := 0; i < len(orders); i += GATE_MAX_SIZE_BATCH {
-			end := i + GATE_MAX_SIZE_BATCH
-			if end > len(orders) {
-				end = len(orders)
-			}
+// record the ladder then send its orders, false when any order was rejected
+func placeLadder(exchange Exchange, journal *journalExchange, record ladderRecord, orders []gateapi.Order, sLOrders []gateapi.SpotPriceTriggeredOrder) bool {
+	record.Orders = len(orders) + len(sLOrders)
+	ladder, err := recordLadder(record)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot record the ladder: %s\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Ladder %d\n", ladder.Id)
+	tagLadderOrders(orders, ladder.Id)
+	journal.ladder = ladder.Id
+	writeJournal(ladder.Id, JOURNAL_PLAN, "", journalPlan{Orders: orders, Triggered: sLOrders}, nil)
+
+	if !record.StopLimit {
+		report := sendLadderOrders(exchange, orders)
+		report.print(pair)
+		return !report.failed()
+	}
 
-			chunk := orders[i:end]
-			//fmt.Printf("Chunk %d: %+v\n", i/GATE_MAX_SIZE_BATCH+1, chunk)
-			sendBatchOrder(client, &ctx, chunk)
-			fmt.Printf("%d orders has been set", len(orders))
+	failed := 0
+	for triggeredOrderIndex := 0; triggeredOrderIndex < len(sLOrders); triggeredOrderIndex++ {
+		id, err := sendTriggeredOrder(exchange, &sLOrders[triggeredOrderIndex])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Triggered order not sent: %s\n", err)
+			failed++
+			continue
 		}
-	} else {
 
-		for triggeredOrderIndex := 0; triggeredOrderIndex < len(sLOrders); triggeredOrderIndex++ {
-			sendTriggeredOrder(client, &ctx, &sLOrders[triggeredOrderIndex])
+		// recorded at once, an interruption must not leave a placed order out of the ladder
+		ladder.TriggeredOrderIds = append(ladder.TriggeredOrderIds, id)
+		if err := updateLadder(ladder); err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot record triggered order %d in ladder %d: %s\n", id, ladder.Id, err)
 		}
 	}
-
+	fmt.Printf("\n%d stop-limit orders: %d accepted, %d rejected\n", len(sLOrders), len(sLOrders)-failed, failed)
+	return failed == 0
 }
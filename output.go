@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+)
+
+const (
+	TABLE_OUTPUT = "table"
+	JSON_OUTPUT  = "json"
+	CSV_OUTPUT   = "csv"
+)
+
+var outputFormat string
+
+// human readable messages, moved to stderr when stdout carries json or csv
+var humanOutput io.Writer = os.Stdout
+
+// exportTable is a list of rows sharing the same columns, every value is a string
+// so decimals are written exactly as the exchange sent them
+type exportTable struct {
+	Columns []string
+	Rows    [][]string
+}
+
+func newExportTable(columns ...string) *exportTable {
+	return &exportTable{Columns: columns}
+}
+
+func (t *exportTable) add(values ...string) {
+	t.Rows = append(t.Rows, values)
+}
+
+// rows as objects keyed by column name
+func (t *exportTable) objects() []map[string]string {
+	objects := []map[string]string{}
+	for _, row := range t.Rows {
+		object := map[string]string{}
+		for i, column := range t.Columns {
+			object[column] = row[i]
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+// write the table to stdout in the selected output format
+func writeTable(table *exportTable) error {
+	if outputFormat == JSON_OUTPUT {
+		return writeJSON(table.objects())
+	}
+
+	writer := csv.NewWriter(os.Stdout)
+	writer.Write(table.Columns)
+	writer.WriteAll(table.Rows)
+	return writer.Error()
+}
+
+func writeJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// computed numbers are exported with a fixed number of decimals
+func decimalString(x float64) string {
+	return strconv.FormatFloat(x, 'f', 8, 64)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gateio/gateapi-go/v6"
+	"github.com/shopspring/decimal"
+)
+
+// order types a plan can be rendered to
+const (
+	LIMIT_ORDER      = "limit"
+	STOP_LIMIT_ORDER = "stop-limit"
+)
+
+// lifetime of a stop-limit order
+const SL_EXPIRATION_SEC int32 = ONE_DAY_SEC
+
+// planLevel is one order of a ladder, in exact decimal
+type planLevel struct {
+	Price  decimal.Decimal
+	Amount decimal.Decimal
+	// quote value of the level, price times amount
+	Quote decimal.Decimal
+	// stop price when the level is sent as a stop-limit order
+	Trigger decimal.Decimal
+}
+
+// Plan is a ladder computed once, independently of the exchange and of the order type sending it
+type Plan struct {
+	Pair           string
+	Side           string
+	PriceMin       float64
+	PriceMax       float64
+	Amount         float64
+	AmountCurrency string
+	Rules          pairRules
+	Levels         []planLevel
+}
+
+// compute the levels of a ladder, amount is in amountCurrency, the base or the quote of the pair
+func planLadder(pair string, side string, priceMin float64, priceMax float64, amount float64, amountCurrency string, ladderSpacing spacing, rules pairRules, dist distribution) (Plan, error) {
+	_, quote, _ := splitPair(pair)
+	plan := Plan{
+		Pair:           pair,
+		Side:           side,
+		PriceMin:       priceMin,
+		PriceMax:       priceMax,
+		Amount:         amount,
+		AmountCurrency: strings.ToUpper(amountCurrency),
+		Rules:          rules,
+	}
+
+	prices, amounts, err := ladderLevels(pair, side, priceMin, priceMax, amount, plan.AmountCurrency == quote, ladderSpacing, rules, dist)
+	if err != nil {
+		return Plan{}, err
+	}
+	if len(prices) == 0 {
+		return Plan{}, errNoOrder(priceMin, priceMax)
+	}
+
+	for i := range prices {
+		plan.Levels = append(plan.Levels, planLevel{
+			Price:   prices[i],
+			Amount:  amounts[i],
+			Quote:   prices[i].Mul(amounts[i]),
+			Trigger: triggerPrice(side, prices[i], rules),
+		})
+	}
+	return plan, nil
+}
+
+// stop price of a level, SL_TRIGGER_OFFSET_PERCENT and at least one tick away from its price:
+// under it for a buy fired when the price rises, above it for a sell fired when the price falls
+func triggerPrice(side string, price decimal.Decimal, rules pairRules) decimal.Decimal {
+	tick := decimal.New(1, -rules.PricePrecision)
+	offset := price.Mul(decimal.NewFromFloat(SL_TRIGGER_OFFSET_PERCENT)).Div(decimal.NewFromInt(100)).Round(rules.PricePrecision)
+	if offset.LessThan(tick) {
+		offset = tick
+	}
+	if side == sell {
+		return price.Add(offset)
+	}
+	return price.Sub(offset)
+}
+
+// the stop price of every level must stay above zero, under the price of a buy and above the price of a sell
+func (p Plan) checkTriggers() error {
+	for _, level := range p.Levels {
+		if p.Side == sell && !level.Trigger.GreaterThan(level.Price) {
+			return validationErrorf("the stop price of the sell level at %s would be %s, it must be above the price", level.price(p.Rules), level.trigger(p.Rules))
+		}
+		if p.Side != sell && !level.Trigger.LessThan(level.Price) {
+			return validationErrorf("the stop price of the buy level at %s would be %s, it must be under the price", level.price(p.Rules), level.trigger(p.Rules))
+		}
+		if !level.Trigger.IsPositive() {
+			return validationErrorf("the stop price of the level at %s would be %s, the price is too close to the tick of the pair (%s) for stop-limit orders", level.price(p.Rules), level.trigger(p.Rules), decimal.New(1, -p.Rules.PricePrecision).String())
+		}
+	}
+	return nil
+}
+
+func (p Plan) inQuote() bool {
+	_, quote, _ := splitPair(p.Pair)
+	return p.AmountCurrency == quote
+}
+
+func (p Plan) totalBase() decimal.Decimal {
+	total := decimal.Zero
+	for _, level := range p.Levels {
+		total = total.Add(level.Amount)
+	}
+	return total
+}
+
+// currency the ladder spends and how much of it: the base total of a sell, the quote total of a buy
+func (p Plan) spending() (string, float64) {
+	base, quote, _ := splitPair(p.Pair)
+	if p.Side == sell {
+		total, _ := p.totalBase().Float64()
+		return base, total
+	}
+	total, _ := p.totalQuote().Float64()
+	return quote, total
+}
+
+func (p Plan) totalQuote() decimal.Decimal {
+	total := decimal.Zero
+	for _, level := range p.Levels {
+		total = total.Add(level.Quote)
+	}
+	return total
+}
+
+func (l planLevel) price(rules pairRules) string {
+	return l.Price.StringFixed(rules.PricePrecision)
+}
+
+func (l planLevel) amount(rules pairRules) string {
+	return l.Amount.StringFixed(rules.AmountPrecision)
+}
+
+func (l planLevel) trigger(rules pairRules) string {
+	return l.Trigger.StringFixed(rules.PricePrecision)
+}
+
+// print the levels as they will be sent with the given order type
+func (p Plan) print(orderType string) {
+	base, quote, _ := splitPair(p.Pair)
+
+	header := fmt.Sprintf("%s %.5f %s between %.5f and %.5f, amount per order: %.4f %s", p.Side, p.Amount, p.AmountCurrency, p.PriceMin, p.PriceMax, p.Amount/float64(len(p.Levels)), p.AmountCurrency)
+	if orderType == STOP_LIMIT_ORDER {
+		header += fmt.Sprintf(", duration: %d day", SL_EXPIRATION_SEC/ONE_DAY_SEC)
+	}
+	fmt.Fprintln(humanOutput, header)
+
+	left := decimal.NewFromFloat(p.Amount)
+	for _, level := range p.Levels {
+		line := fmt.Sprintf("price: %s %s, ", level.price(p.Rules), quote)
+		if orderType == STOP_LIMIT_ORDER {
+			line += fmt.Sprintf("Stop price: %s %s, ", level.trigger(p.Rules), quote)
+		}
+		fmt.Fprintf(humanOutput, "%samount: %s %s, total: %s %s, amount left: %s %s\n", line, level.amount(p.Rules), base, level.Quote.String(), quote, left.String(), p.AmountCurrency)
+
+		if p.inQuote() {
+			left = left.Sub(level.Quote)
+		} else {
+			left = left.Sub(level.Amount)
+		}
+	}
+
+	if p.inQuote() {
+		fmt.Fprintf(humanOutput, "Total amount in order: %s %s\n", p.totalQuote().String(), quote)
+	} else {
+		fmt.Fprintf(humanOutput, "Total amount in order: %s %s\n", p.totalBase().String(), base)
+	}
+}
+
+// print the totals and the average price of the plan
+func (p Plan) printSummary() {
+	base, quote, _ := splitPair(p.Pair)
+	totalBase := p.totalBase()
+	totalQuote := p.totalQuote()
+
+	avgPrice := decimal.Zero
+	if totalBase.IsPositive() {
+		avgPrice = totalQuote.DivRound(totalBase, LADDER_DIVISION_PRECISION)
+	}
+	fmt.Fprintf(humanOutput, "\nPlan: %d orders, %s %s, %s %s, average price %s %s\n", len(p.Levels), totalBase.String(), base, totalQuote.String(), quote, avgPrice.StringFixed(p.Rules.PricePrecision+2), quote)
+}
+
+// the plan rendered as limit orders
+func (p Plan) limitOrders(timeInForce string) []gateapi.Order {
+	var orders []gateapi.Order
+	for _, level := range p.Levels {
+		orders = append(orders, gateapi.Order{
+			CurrencyPair: p.Pair,
+			Side:         p.Side,
+			Price:        level.price(p.Rules),
+			Amount:       level.amount(p.Rules),
+			TimeInForce:  timeInForce,
+		})
+	}
+	return orders
+}
+
+// the plan rendered as stop-limit orders, each triggered at the stop price of its level
+func (p Plan) stopLimitOrders() []gateapi.SpotPriceTriggeredOrder {
+	rule := SL_BUY_RULE
+	if p.Side == sell {
+		rule = SL_SELL_RULE
+	}
+
+	var orders []gateapi.SpotPriceTriggeredOrder
+	for _, level := range p.Levels {
+		orders = append(orders, gateapi.SpotPriceTriggeredOrder{
+			Market: p.Pair,
+			Put: gateapi.SpotPricePutOrder{
+				Type:        "limit",
+				Side:        p.Side,
+				Price:       level.price(p.Rules),
+				Amount:      level.amount(p.Rules),
+				Account:     "normal",
+				TimeInForce: "ioc",
+			},
+			Trigger: gateapi.SpotPriceTrigger{
+				Price:      level.trigger(p.Rules),
+				Rule:       rule,
+				Expiration: SL_EXPIRATION_SEC,
+			},
+		})
+	}
+	return orders
+}
+
+// the plan rendered as an export table, for json and csv output
+func (p Plan) table(orderType string) *exportTable {
+	table := newExportTable("type", "side", "price", "trigger_price", "amount", "total")
+	for _, level := range p.Levels {
+		trigger := ""
+		if orderType == STOP_LIMIT_ORDER {
+			trigger = level.trigger(p.Rules)
+		}
+		table.add(orderType, p.Side, level.price(p.Rules), trigger, level.amount(p.Rules), level.Quote.String())
+	}
+	return table
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const PNL_COMMAND = "pnl"
+
+const (
+	FIFO_COST    = "fifo"
+	AVERAGE_COST = "average"
+)
+
+const PNL_DEFAULT_DAYS = 30
+const PNL_DATE_LAYOUT = "2006-01-02"
+
+var pnlCost string
+var pnlFromDate string
+var pnlToDate string
+
+var pnlFrom time.Time
+var pnlTo time.Time
+
+// quantity bought at a price, not sold yet
+type costLot struct {
+	Amount float64
+	Price  float64
+}
+
+// position is the inventory built by the trades of the range
+type position struct {
+	method string
+	lots   []costLot
+
+	// average cost accounting
+	amount float64
+	cost   float64
+
+	realized float64
+	// sold without a buy in the range to match
+	unmatched float64
+}
+
+func (p *position) buy(amount float64, price float64) {
+	if p.method == FIFO_COST {
+		p.lots = append(p.lots, costLot{Amount: amount, Price: price})
+		return
+	}
+	p.amount += amount
+	p.cost += amount * price
+}
+
+// sell from the position and return the pnl it realized
+func (p *position) sell(amount float64, price float64) float64 {
+	before := p.realized
+	p.reduce(amount, price)
+	return p.realized - before
+}
+
+func (p *position) reduce(amount float64, price float64) {
+	if p.method == FIFO_COST {
+		for amount > 0 && len(p.lots) > 0 {
+			matched := amount
+			if p.lots[0].Amount < matched {
+				matched = p.lots[0].Amount
+			}
+			p.realized += matched * (price - p.lots[0].Price)
+			p.lots[0].Amount -= matched
+			amount -= matched
+			if p.lots[0].Amount <= 0 {
+				p.lots = p.lots[1:]
+			}
+		}
+		p.unmatched += amount
+		return
+	}
+
+	matched := amount
+	if p.amount < matched {
+		matched = p.amount
+	}
+	if matched > 0 {
+		avgCost := p.cost / p.amount
+		p.realized += matched * (price - avgCost)
+		p.cost -= matched * avgCost
+		p.amount -= matched
+	}
+	p.unmatched += amount - matched
+}
+
+// quantity held and its cost
+func (p *position) open() (float64, float64) {
+	if p.method != FIFO_COST {
+		return p.amount, p.cost
+	}
+	amount := 0.0
+	cost := 0.0
+	for _, lot := range p.lots {
+		amount += lot.Amount
+		cost += lot.Amount * lot.Price
+	}
+	return amount, cost
+}
+
+func checkPnlArgs() {
+	error := false
+
+	if pnlCost != FIFO_COST && pnlCost != AVERAGE_COST {
+		fmt.Fprintf(os.Stderr, "Cost accepted value. fifo or average\n")
+		error = true
+	}
+
+	pnlTo = time.Now()
+	if pnlToDate != "" {
+		to, err := time.ParseInLocation(PNL_DATE_LAYOUT, pnlToDate, time.Local)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "to must be formatted as %s\n", PNL_DATE_LAYOUT)
+			error = true
+		}
+		// the whole last day is included
+		pnlTo = to.AddDate(0, 0, 1)
+	}
+
+	days := lastDays
+	if days <= 0 {
+		days = PNL_DEFAULT_DAYS
+	}
+	pnlFrom = pnlTo.AddDate(0, 0, -days)
+	if pnlFromDate != "" {
+		from, err := time.ParseInLocation(PNL_DATE_LAYOUT, pnlFromDate, time.Local)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "from must be formatted as %s\n", PNL_DATE_LAYOUT)
+			error = true
+		}
+		pnlFrom = from
+	}
+
+	if !error && !pnlFrom.Before(pnlTo) {
+		fmt.Fprintf(os.Stderr, "from must be before to\n")
+		error = true
+	}
+
+	if error {
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// report the trades of the pair over the range with realized and unrealized pnl
+func runPnl(exchange Exchange) {
+	base, quote, _ := splitPair(pair)
+
+	trades, err := getMyTrades(exchange, pair, pnlFrom.Unix(), pnlTo.Unix())
+	exitOnError(err)
+	trades = filterLadderTrades(trades)
+	currentPrice, err := getTickerPrice(exchange, pair)
+	exitOnError(err)
+
+	fmt.Fprintf(humanOutput, "Trades on %s from %s to %s\n", pair, pnlFrom.Format(time.RFC3339), pnlTo.Format(time.RFC3339))
+	if len(trades) == 0 && outputFormat == TABLE_OUTPUT {
+		fmt.Println("No trade")
+		return
+	}
+
+	table := newExportTable("id", "time", "pair", "side", "role", "price", "amount", "total", "fee", "fee_currency", "order_id", "text", "realized_pnl")
+
+	book := position{method: pnlCost}
+	var boughtBase, boughtQuote, soldBase, soldQuote, feesQuote float64
+	var buys, sells int
+	otherFees := map[string]float64{}
+
+	for _, trade := range trades {
+		amount, err := parseFloatField("trade amount", trade.Amount)
+		exitOnError(err)
+		price, err := parseFloatField("trade price", trade.Price)
+		exitOnError(err)
+
+		realized := 0.0
+		if trade.Side == buy {
+			buys++
+			boughtBase += amount
+			boughtQuote += amount * price
+			book.buy(amount, price)
+		} else {
+			sells++
+			soldBase += amount
+			soldQuote += amount * price
+			realized = book.sell(amount, price)
+		}
+		table.add(trade.Id, tradeTime(trade).Format(time.RFC3339), trade.CurrencyPair, trade.Side, trade.Role, trade.Price, trade.Amount, decimalString(amount*price), trade.Fee, trade.FeeCurrency, trade.OrderId, trade.Text, decimalString(realized))
+
+		// fees are valued in the quote currency at the price of the trade
+		fee, _ := parseFloatField("trade fee", trade.Fee)
+		switch strings.ToUpper(trade.FeeCurrency) {
+		case quote:
+			feesQuote += fee
+		case base:
+			feesQuote += fee * price
+		default:
+			if fee > 0 {
+				otherFees[trade.FeeCurrency] += fee
+			}
+		}
+	}
+
+	openAmount, openCost := book.open()
+	if outputFormat == CSV_OUTPUT {
+		exitOnError(writeTable(table))
+		return
+	}
+	if outputFormat == JSON_OUTPUT {
+		summary := map[string]string{
+			"pair":             pair,
+			"from":             pnlFrom.Format(time.RFC3339),
+			"to":               pnlTo.Format(time.RFC3339),
+			"cost":             pnlCost,
+			"trades":           strconv.Itoa(len(trades)),
+			"bought_amount":    decimalString(boughtBase),
+			"bought_total":     decimalString(boughtQuote),
+			"sold_amount":      decimalString(soldBase),
+			"sold_total":       decimalString(soldQuote),
+			"fees":             decimalString(feesQuote),
+			"realized_pnl":     decimalString(book.realized),
+			"realized_pnl_net": decimalString(book.realized - feesQuote),
+			"unmatched_amount": decimalString(book.unmatched),
+			"open_amount":      decimalString(openAmount),
+			"open_cost":        decimalString(openCost),
+			"current_price":    decimalString(currentPrice),
+			"unrealized_pnl":   decimalString(openAmount*currentPrice - openCost),
+		}
+		for currency, fee := range otherFees {
+			summary["fees_"+strings.ToLower(currency)] = decimalString(fee)
+		}
+		exitOnError(writeJSON(map[string]interface{}{"summary": summary, "trades": table.objects()}))
+		return
+	}
+
+	fmt.Printf("%d trades: %d buys, %d sells\n", len(trades), buys, sells)
+	if boughtBase > 0 {
+		fmt.Printf("Bought: %.4f %s for %.4f %s, avg price: %.6f %s\n", boughtBase, base, boughtQuote, quote, boughtQuote/boughtBase, quote)
+	}
+	if soldBase > 0 {
+		fmt.Printf("Sold: %.4f %s for %.4f %s, avg price: %.6f %s\n", soldBase, base, soldQuote, quote, soldQuote/soldBase, quote)
+	}
+
+	fmt.Printf("Fees: %.4f %s", feesQuote, quote)
+	var feeCurrencies []string
+	for currency := range otherFees {
+		feeCurrencies = append(feeCurrencies, currency)
+	}
+	sort.Strings(feeCurrencies)
+	for _, currency := range feeCurrencies {
+		fmt.Printf(" + %.6f %s", otherFees[currency], currency)
+	}
+	fmt.Println()
+
+	fmt.Printf("Realized PnL (%s): %.4f %s, net of fees: %.4f %s\n", pnlCost, book.realized, quote, book.realized-feesQuote, quote)
+	if book.unmatched > 0 {
+		fmt.Printf("Warning: %.4f %s sold without a buy in the range, their pnl is not counted\n", book.unmatched, base)
+	}
+
+	if openAmount > 0 {
+		fmt.Printf("Open position: %.4f %s, avg cost: %.6f %s, unrealized PnL at %.6f %s: %.4f %s\n", openAmount, base, openCost/openAmount, quote, currentPrice, quote, openAmount*currentPrice-openCost, quote)
+	}
+}
+
+// time of the trade, in milliseconds when the exchange gives it
+func tradeTime(trade gateapi.Trade) time.Time {
+	if ms, err := strconv.ParseFloat(trade.CreateTimeMs, 64); err == nil && ms > 0 {
+		return time.UnixMilli(int64(ms)).UTC()
+	}
+	seconds, _ := strconv.ParseInt(trade.CreateTime, 10, 64)
+	return time.Unix(seconds, 0).UTC()
+}
+
+// keep the trades of the selected ladder, a fill carries the text of its order
+func filterLadderTrades(trades []gateapi.Trade) []gateapi.Trade {
+	if ladderId == 0 {
+		return trades
+	}
+
+	var selected []gateapi.Trade
+	for _, trade := range trades {
+		if ladderIdFromText(trade.Text) == ladderId {
+			selected = append(selected, trade)
+		}
+	}
+	return selected
+}
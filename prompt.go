@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const (
+	ON_CROSS_CONTINUE   = "continue"
+	ON_CROSS_STOP_LIMIT = "stop-limit"
+	ON_CROSS_ABORT      = "abort"
+)
+
+var assumeYes bool
+var onCross string
+var allowExistingOrders bool
+
+// one scanner for the whole run, a second one would lose the answers buffered by the first
+var stdinScanner = bufio.NewScanner(os.Stdin)
+
+// stdin is a terminal someone can answer from, not a pipe nor /dev/null
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// exit when a question cannot be asked, hint is the flag that answers it
+func requireTerminal(hint string) {
+	if !stdinIsTerminal() {
+		fmt.Fprintf(os.Stderr, "\nstdin is not a terminal and no decision was given, use %s\n", hint)
+		os.Exit(1)
+	}
+}
+
+func readAnswer() string {
+	stdinScanner.Scan()
+	return strings.ToLower(strings.TrimSpace(stdinScanner.Text()))
+}
+
+// ask a y/N question, -yes answers it without reading stdin
+func confirm(question string) bool {
+	if assumeYes {
+		fmt.Printf("%s [y/N] y (-yes)\n", question)
+		return true
+	}
+	requireTerminal("-yes")
+
+	fmt.Printf("%s [y/N] ", question)
+	return readAnswer() == "y"
+}
+
+func checkPromptArgs() bool {
+	if onCross != "" && onCross != ON_CROSS_CONTINUE && onCross != ON_CROSS_STOP_LIMIT && onCross != ON_CROSS_ABORT {
+		fmt.Fprintf(os.Stderr, "On-cross accepted value. continue, stop-limit or abort\n")
+		return false
+	}
+	return true
+}
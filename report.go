@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const (
+	ORDER_ACCEPTED = "accepted"
+	ORDER_FILLED   = "filled"
+	ORDER_PARTIAL  = "partially filled"
+	ORDER_REJECTED = "rejected"
+	// accepted then closed by the exchange with nothing filled, e.g. an ioc order finding no match
+	ORDER_CANCELLED = "cancelled"
+)
+
+// outcome of one order of a batch submission
+type orderResult struct {
+	Status      string
+	Side        string
+	Price       string
+	Amount      string
+	Filled      float64
+	FilledTotal float64
+	Id          string
+	Reason      string
+}
+
+type batchReport struct {
+	results []orderResult
+}
+
+// record the answer of the exchange for each order of a batch, in request order
+func (r *batchReport) add(requests []gateapi.Order, results []gateapi.BatchOrder) {
+	for i, request := range requests {
+		if i >= len(results) {
+			r.results = append(r.results, orderResult{Status: ORDER_REJECTED, Side: request.Side, Price: request.Price, Amount: request.Amount, Reason: "no answer for this order"})
+			continue
+		}
+		r.results = append(r.results, orderResultFrom(request, results[i]))
+	}
+}
+
+// record a batch that failed, the orders found placed despite the error keep their outcome
+func (r *batchReport) addFailed(requests []gateapi.Order, placed []gateapi.BatchOrder, err error) {
+	byText := map[string]gateapi.BatchOrder{}
+	for _, result := range placed {
+		byText[result.Text] = result
+	}
+	for _, request := range requests {
+		if result, ok := byText[request.Text]; ok && request.Text != "" {
+			r.results = append(r.results, orderResultFrom(request, result))
+			continue
+		}
+		r.results = append(r.results, orderResult{Status: ORDER_REJECTED, Side: request.Side, Price: request.Price, Amount: request.Amount, Reason: err.Error()})
+	}
+}
+
+func orderResultFrom(request gateapi.Order, result gateapi.BatchOrder) orderResult {
+	orderResult := orderResult{Side: request.Side, Price: request.Price, Amount: request.Amount, Id: result.Id}
+	if !result.Succeeded {
+		orderResult.Status = ORDER_REJECTED
+		orderResult.Reason = fmt.Sprintf("%s: %s", result.Label, result.Message)
+		return orderResult
+	}
+
+	amount, _ := strconv.ParseFloat(result.Amount, 64)
+	left, _ := strconv.ParseFloat(result.Left, 64)
+	orderResult.FilledTotal, _ = strconv.ParseFloat(result.FilledTotal, 64)
+	orderResult.Filled = amount - left
+
+	// "closed" means filled for Gate.io, an order ended by the exchange is "cancelled"
+	// and finish_as tells why: ioc, fok, poc, stp... Filled amounts are checked first.
+	closed := result.Status == "cancelled" || (result.FinishAs != "" && result.FinishAs != "open" && result.FinishAs != "filled")
+	if closed && result.FinishAs != "" {
+		orderResult.Reason = "finished as " + result.FinishAs
+	}
+
+	switch {
+	case result.Left != "" && left <= 0:
+		orderResult.Status = ORDER_FILLED
+		orderResult.Reason = ""
+	case result.Left != "" && left < amount:
+		orderResult.Status = ORDER_PARTIAL
+	case closed:
+		orderResult.Status = ORDER_CANCELLED
+		orderResult.Filled = 0
+	default:
+		orderResult.Status = ORDER_ACCEPTED
+		orderResult.Filled = 0
+	}
+	return orderResult
+}
+
+func (r *batchReport) count(status string) int {
+	count := 0
+	for _, result := range r.results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// an order rejected, or closed without any fill, is a failure of the run
+func (r *batchReport) failed() bool {
+	return r.count(ORDER_REJECTED) > 0 || r.count(ORDER_CANCELLED) > 0
+}
+
+// print one line per order then the totals
+func (r *batchReport) print(pair string) {
+	base, quote, _ := splitPair(pair)
+
+	table := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(table, "STATUS\tSIDE\tPRICE (%s)\tAMOUNT (%s)\tFILLED (%s)\tAVG PRICE\tID\tREASON\n", quote, base, base)
+	for _, result := range r.results {
+		avgPrice := "-"
+		if result.Filled > 0 {
+			avgPrice = fmt.Sprintf("%.4f", result.FilledTotal/result.Filled)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%.4f\t%s\t%s\t%s\n", result.Status, result.Side, result.Price, result.Amount, result.Filled, avgPrice, result.Id, result.Reason)
+	}
+	table.Flush()
+
+	filledBase := 0.0
+	filledQuote := 0.0
+	for _, result := range r.results {
+		filledBase += result.Filled
+		filledQuote += result.FilledTotal
+	}
+
+	fmt.Printf("\n%d orders: %d accepted, %d filled, %d partially filled, %d cancelled, %d rejected\n", len(r.results), r.count(ORDER_ACCEPTED), r.count(ORDER_FILLED), r.count(ORDER_PARTIAL), r.count(ORDER_CANCELLED), r.count(ORDER_REJECTED))
+	if filledBase > 0 {
+		fmt.Printf("Filled immediately: %.4f %s for %.4f %s\n", filledBase, base, filledQuote, quote)
+	}
+}
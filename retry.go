@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const (
+	RETRY_MAX_ATTEMPTS = 5
+	RETRY_BASE_DELAY   = 500 * time.Millisecond
+	RETRY_MAX_DELAY    = 8 * time.Second
+
+	// finished orders are looked up from this long before the first attempt, for the clock skew
+	RETRY_LOOKUP_SKEW_SEC = 60
+)
+
+// minimum time between two requests of a group, from the documented Gate.io limits
+var GATE_RATE_LIMITS = map[string]time.Duration{
+	"public":  10 * time.Second / 200, // 200 requests per 10s per endpoint
+	"private": 10 * time.Second / 200, // 200 requests per 10s per endpoint
+	"order":   time.Second / 10,       // 10 orders per second per market
+	"cancel":  time.Second / 200,      // 200 cancellations per second
+}
+
+type rateLimiter struct {
+	every time.Duration
+	next  time.Time
+}
+
+// block until the next request of the group is allowed
+func (l *rateLimiter) wait() {
+	now := time.Now()
+	if l.next.After(now) {
+		time.Sleep(l.next.Sub(now))
+		now = l.next
+	}
+	l.next = now.Add(l.every)
+}
+
+// retryExchange rate limits the calls and retries the transient failures.
+// A create is only retried when the order text tells whether it reached the exchange.
+type retryExchange struct {
+	Exchange
+	mu       sync.Mutex
+	limiters map[string]*rateLimiter
+}
+
+func newRetryExchange(exchange Exchange) *retryExchange {
+	return &retryExchange{Exchange: exchange, limiters: map[string]*rateLimiter{}}
+}
+
+// wait for the limiter of the group, keyed further by endpoint or market
+func (r *retryExchange) wait(group string, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := group + ":" + key
+	limiter, ok := r.limiters[name]
+	if !ok {
+		limiter = &rateLimiter{every: GATE_RATE_LIMITS[group]}
+		r.limiters[name] = limiter
+	}
+	limiter.wait()
+}
+
+// the request was refused before being processed, sending it again is always safe
+func isRateLimited(err error) bool {
+	switch e := err.(type) {
+	case gateapi.GateAPIError:
+		return e.Label == "TOO_MANY_REQUESTS"
+	case gateapi.GenericOpenAPIError:
+		return strings.HasPrefix(e.Error(), "429")
+	}
+	return false
+}
+
+// the request may succeed later: rate limits, server errors and network failures
+func isTransient(err error) bool {
+	if isRateLimited(err) {
+		return true
+	}
+	switch e := err.(type) {
+	case gateapi.GateAPIError:
+		return e.Label == "SERVER_ERROR" || e.Label == "TOO_BUSY"
+	case gateapi.GenericOpenAPIError:
+		return strings.HasPrefix(e.Error(), "5")
+	}
+	// no answer from the exchange
+	return true
+}
+
+// exponential backoff with jitter, between half and the full delay
+func backoffDelay(attempt int) time.Duration {
+	delay := RETRY_BASE_DELAY << (attempt - 1)
+	if delay > RETRY_MAX_DELAY || delay <= 0 {
+		delay = RETRY_MAX_DELAY
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func withRetry[T any](r *retryExchange, endpoint string, group string, key string, retryable func(error) bool, call func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		r.wait(group, key)
+		result, err := call()
+		if err == nil || attempt == RETRY_MAX_ATTEMPTS || !retryable(err) {
+			return result, err
+		}
+
+		delay := backoffDelay(attempt)
+		fmt.Fprintf(os.Stderr, "%s failed (%s), retrying in %s (%d/%d)\n", endpoint, err, delay.Round(time.Millisecond), attempt+1, RETRY_MAX_ATTEMPTS)
+		time.Sleep(delay)
+	}
+}
+
+// orders of the pair carrying one of the texts, every open one and those finished since the first attempt
+func (r *retryExchange) findByText(pair string, texts map[string]bool, since int64) (map[string]gateapi.Order, error) {
+	open, err := getOpenOrders(r, pair)
+	if err != nil {
+		return nil, err
+	}
+	finished, err := getFinishedOrders(r, pair, "", since-RETRY_LOOKUP_SKEW_SEC, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[string]gateapi.Order{}
+	for _, order := range append(open, finished...) {
+		if texts[order.Text] {
+			found[order.Text] = order
+		}
+	}
+	return found, nil
+}
+
+// a create can be retried on any transient failure only if its text identifies it
+func createRetryable(texts ...string) func(error) bool {
+	for _, text := range texts {
+		if text == "" {
+			return isRateLimited
+		}
+	}
+	return isTransient
+}
+
+func (r *retryExchange) CreateOrder(order gateapi.Order) (gateapi.Order, error) {
+	attempted := false
+	since := time.Now().Unix()
+	return withRetry(r, "create order", "order", order.CurrencyPair, createRetryable(order.Text), func() (gateapi.Order, error) {
+		if attempted {
+			found, err := r.findByText(order.CurrencyPair, map[string]bool{order.Text: true}, since)
+			if err != nil {
+				return gateapi.Order{}, err
+			}
+			if placed, ok := found[order.Text]; ok {
+				return placed, nil
+			}
+		}
+		attempted = true
+		return r.Exchange.CreateOrder(order)
+	})
+}
+
+func (r *retryExchange) CreateBatchOrders(orders []gateapi.Order) ([]gateapi.BatchOrder, error) {
+	if len(orders) == 0 {
+		return r.Exchange.CreateBatchOrders(orders)
+	}
+
+	texts := map[string]bool{}
+	var textList []string
+	for _, order := range orders {
+		texts[order.Text] = true
+		textList = append(textList, order.Text)
+	}
+
+	pending := orders
+	placed := map[string]gateapi.BatchOrder{}
+	attempted := false
+	since := time.Now().Unix()
+	result, err := withRetry(r, "create batch orders", "order", orders[0].CurrencyPair, createRetryable(textList...), func() ([]gateapi.BatchOrder, error) {
+		if attempted {
+			// only send again the orders the exchange does not know
+			found := map[string]gateapi.Order{}
+			looked := map[string]bool{}
+			for _, order := range pending {
+				if looked[order.CurrencyPair] {
+					continue
+				}
+				looked[order.CurrencyPair] = true
+				pairFound, err := r.findByText(order.CurrencyPair, texts, since)
+				if err != nil {
+					return nil, err
+				}
+				for text, existing := range pairFound {
+					found[text] = existing
+				}
+			}
+
+			var missing []gateapi.Order
+			for _, order := range pending {
+				if existing, ok := found[order.Text]; ok {
+					placed[order.Text] = batchOrderFrom(existing)
+				} else {
+					missing = append(missing, order)
+				}
+			}
+			pending = missing
+			if len(pending) == 0 {
+				return nil, nil
+			}
+		}
+		attempted = true
+		return r.Exchange.CreateBatchOrders(pending)
+	})
+	if len(placed) == 0 {
+		return result, err
+	}
+	if err != nil {
+		// the orders found on the exchange are live, the caller must not report them as rejected
+		var found []gateapi.BatchOrder
+		for _, order := range orders {
+			if existing, ok := placed[order.Text]; ok {
+				found = append(found, existing)
+			}
+		}
+		return found, err
+	}
+
+	// results in the order of the request, found orders first
+	merged := make([]gateapi.BatchOrder, 0, len(orders))
+	next := 0
+	for _, order := range orders {
+		if existing, ok := placed[order.Text]; ok {
+			merged = append(merged, existing)
+		} else if next < len(result) {
+			merged = append(merged, result[next])
+			next++
+		}
+	}
+	return merged, nil
+}
+
+func batchOrderFrom(order gateapi.Order) gateapi.BatchOrder {
+	return gateapi.BatchOrder{
+		Text:         order.Text,
+		Succeeded:    true,
+		Id:           order.Id,
+		CreateTime:   order.CreateTime,
+		CreateTimeMs: order.CreateTimeMs,
+		Status:       order.Status,
+		CurrencyPair: order.CurrencyPair,
+		Type:         order.Type,
+		Account:      order.Account,
+		Side:         order.Side,
+		Amount:       order.Amount,
+		Price:        order.Price,
+		TimeInForce:  order.TimeInForce,
+		Left:         order.Left,
+		FillPrice:    order.FillPrice,
+		FilledTotal:  order.FilledTotal,
+		Fee:          order.Fee,
+		FeeCurrency:  order.FeeCurrency,
+	}
+}
+
+// triggered orders have no text, they are only sent again when surely refused
+func (r *retryExchange) CreateSpotPriceTriggeredOrder(order gateapi.SpotPriceTriggeredOrder) (gateapi.TriggerOrderResponse, error) {
+	return withRetry(r, "create triggered order", "order", order.Market, isRateLimited, func() (gateapi.TriggerOrderResponse, error) {
+		return r.Exchange.CreateSpotPriceTriggeredOrder(order)
+	})
+}
+
+func (r *retryExchange) CancelOrder(pair string, orderId string) (gateapi.Order, error) {
+	return withRetry(r, "cancel order", "cancel", "", isTransient, func() (gateapi.Order, error) {
+		return r.Exchange.CancelOrder(pair, orderId)
+	})
+}
+
+func (r *retryExchange) CancelBatchOrders(orders []gateapi.CancelBatchOrder) ([]gateapi.CancelOrderResult, error) {
+	return withRetry(r, "cancel batch orders", "cancel", "", isTransient, func() ([]gateapi.CancelOrderResult, error) {
+		return r.Exchange.CancelBatchOrders(orders)
+	})
+}
+
+func (r *retryExchange) CancelSpotPriceTriggeredOrder(orderId string) (gateapi.SpotPriceTriggeredOrder, error) {
+	return withRetry(r, "cancel triggered order", "cancel", "", isTransient, func() (gateapi.SpotPriceTriggeredOrder, error) {
+		return r.Exchange.CancelSpotPriceTriggeredOrder(orderId)
+	})
+}
+
+func (r *retryExchange) GetAccountDetail() (gateapi.AccountDetail, error) {
+	return withRetry(r, "get account detail", "private", "account_detail", isTransient, r.Exchange.GetAccountDetail)
+}
+
+func (r *retryExchange) GetCurrencyPair(pair string) (gateapi.CurrencyPair, error) {
+	return withRetry(r, "get currency pair", "public", "currency_pair", isTransient, func() (gateapi.CurrencyPair, error) {
+		return r.Exchange.GetCurrencyPair(pair)
+	})
+}
+
+func (r *retryExchange) ListTickers(pair string) ([]gateapi.Ticker, error) {
+	return withRetry(r, "list tickers", "public", "tickers", isTransient, func() ([]gateapi.Ticker, error) {
+		return r.Exchange.ListTickers(pair)
+	})
+}
+
+func (r *retryExchange) ListOrderBook(pair string, options *gateapi.ListOrderBookOpts) (gateapi.OrderBook, error) {
+	return withRetry(r, "list order book", "public", "order_book", isTransient, func() (gateapi.OrderBook, error) {
+		return r.Exchange.ListOrderBook(pair, options)
+	})
+}
+
+func (r *retryExchange) ListSpotAccounts() ([]gateapi.SpotAccount, error) {
+	return withRetry(r, "list balances", "private", "spot_accounts", isTransient, r.Exchange.ListSpotAccounts)
+}
+
+func (r *retryExchange) ListAllOpenOrders(options *gateapi.ListAllOpenOrdersOpts) ([]gateapi.OpenOrders, error) {
+	return withRetry(r, "list open orders", "private", "open_orders", isTransient, func() ([]gateapi.OpenOrders, error) {
+		return r.Exchange.ListAllOpenOrders(options)
+	})
+}
+
+func (r *retryExchange) ListOrders(pair string, status string, options *gateapi.ListOrdersOpts) ([]gateapi.Order, error) {
+	return withRetry(r, "list "+status+" orders", "private", "list_orders", isTransient, func() ([]gateapi.Order, error) {
+		return r.Exchange.ListOrders(pair, status, options)
+	})
+}
+
+func (r *retryExchange) ListMyTrades(pair string, options *gateapi.ListMyTradesOpts) ([]gateapi.Trade, error) {
+	return withRetry(r, "list my trades", "private", "my_trades", isTransient, func() ([]gateapi.Trade, error) {
+		return r.Exchange.ListMyTrades(pair, options)
+	})
+}
+
+func (r *retryExchange) ListSpotPriceTriggeredOrders(status string, options *gateapi.ListSpotPriceTriggeredOrdersOpts) ([]gateapi.SpotPriceTriggeredOrder, error) {
+	return withRetry(r, "list "+status+" triggered orders", "private", "triggered_orders", isTransient, func() ([]gateapi.SpotPriceTriggeredOrder, error) {
+		return r.Exchange.ListSpotPriceTriggeredOrders(status, options)
+	})
+}
+
+func (r *retryExchange) GetOrder(pair string, orderId string) (gateapi.Order, error) {
+	return withRetry(r, "get order", "private", "get_order", isTransient, func() (gateapi.Order, error) {
+		return r.Exchange.GetOrder(pair, orderId)
+	})
+}
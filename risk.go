@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antihax/optional"
+	"github.com/gateio/gateapi-go/v6"
+)
+
+// levels of the order book read to estimate the immediate fills
+const RISK_BOOK_DEPTH int32 = 100
+
+// largest quote value the ladder may take from the book, 0 for no limit
+var maxTakerExposure float64
+
+type bookLevel struct {
+	Price  float64
+	Amount float64
+}
+
+// levelRisk is one order of the ladder matched against the book
+type levelRisk struct {
+	Price      float64
+	Amount     float64
+	Crosses    bool
+	FillAmount float64
+	FillQuote  float64
+
+	// stop-limit level whose trigger is already reached, it is sent to the book as soon as it is placed
+	Triggered bool
+}
+
+type riskReport struct {
+	BestBid float64
+	BestAsk float64
+	Levels  []levelRisk
+
+	// immediate fills of the whole ladder
+	FillAmount float64
+	FillQuote  float64
+}
+
+func (r riskReport) triggered() []levelRisk {
+	var triggered []levelRisk
+	for _, level := range r.Levels {
+		if level.Triggered {
+			triggered = append(triggered, level)
+		}
+	}
+	return triggered
+}
+
+func (r riskReport) crossing() []levelRisk {
+	var crossing []levelRisk
+	for _, level := range r.Levels {
+		if level.Crosses {
+			crossing = append(crossing, level)
+		}
+	}
+	return crossing
+}
+
+// average fill price, and how far it is from the best price of the book in percent
+func (r riskReport) slippage(side string) (float64, float64) {
+	if r.FillAmount <= 0 {
+		return 0, 0
+	}
+	avgPrice := r.FillQuote / r.FillAmount
+	if side == buy && r.BestAsk > 0 {
+		return avgPrice, (avgPrice - r.BestAsk) / r.BestAsk * 100
+	}
+	if side == sell && r.BestBid > 0 {
+		return avgPrice, (r.BestBid - avgPrice) / r.BestBid * 100
+	}
+	return avgPrice, 0
+}
+
+func parseBookLevels(levels [][]string) ([]bookLevel, error) {
+	var book []bookLevel
+	for _, level := range levels {
+		if len(level) < 2 {
+			continue
+		}
+		price, err := parseFloatField("book price", level[0])
+		if err != nil {
+			return nil, err
+		}
+		amount, err := parseFloatField("book amount", level[1])
+		if err != nil {
+			return nil, err
+		}
+		book = append(book, bookLevel{Price: price, Amount: amount})
+	}
+	return book, nil
+}
+
+// match every level of the ladder against the live book, the most aggressive first,
+// each level takes the depth left by the previous ones
+func checkRisk(exchange Exchange, pair string, orders []gateapi.Order) (riskReport, error) {
+	result, err := exchange.ListOrderBook(pair, &gateapi.ListOrderBookOpts{Limit: optional.NewInt32(RISK_BOOK_DEPTH)})
+	if err != nil {
+		return riskReport{}, newAPIError("list order book", err)
+	}
+	asks, err := parseBookLevels(result.Asks)
+	if err != nil {
+		return riskReport{}, err
+	}
+	bids, err := parseBookLevels(result.Bids)
+	if err != nil {
+		return riskReport{}, err
+	}
+
+	var report riskReport
+	if len(asks) > 0 {
+		report.BestAsk = asks[0].Price
+	}
+	if len(bids) > 0 {
+		report.BestBid = bids[0].Price
+	}
+
+	for _, order := range orders {
+		price, err := parseFloatField("order price", order.Price)
+		if err != nil {
+			return riskReport{}, err
+		}
+		amount, err := parseFloatField("order amount", order.Amount)
+		if err != nil {
+			return riskReport{}, err
+		}
+		report.Levels = append(report.Levels, levelRisk{Price: price, Amount: amount})
+	}
+	if len(orders) == 0 {
+		return report, nil
+	}
+
+	orderSide := orders[0].Side
+	levels := make([]*levelRisk, len(report.Levels))
+	for i := range report.Levels {
+		levels[i] = &report.Levels[i]
+	}
+	sort.SliceStable(levels, func(i, j int) bool {
+		if orderSide == buy {
+			return levels[i].Price > levels[j].Price
+		}
+		return levels[i].Price < levels[j].Price
+	})
+
+	book := bids
+	if orderSide == buy {
+		book = asks
+	}
+	for _, level := range levels {
+		left := level.Amount
+		for i := range book {
+			if left <= 0 {
+				break
+			}
+			if (orderSide == buy && book[i].Price > level.Price) || (orderSide == sell && book[i].Price < level.Price) {
+				break
+			}
+			if book[i].Amount <= 0 {
+				continue
+			}
+			level.Crosses = true
+
+			taken := left
+			if book[i].Amount < taken {
+				taken = book[i].Amount
+			}
+			book[i].Amount -= taken
+			left -= taken
+			level.FillAmount += taken
+			level.FillQuote += taken * book[i].Price
+		}
+		report.FillAmount += level.FillAmount
+		report.FillQuote += level.FillQuote
+	}
+	return report, nil
+}
+
+// whether the price already reached the trigger of a stop-limit order
+func triggerReached(order gateapi.SpotPriceTriggeredOrder, price float64) (bool, error) {
+	trigger, err := parseFloatField("trigger price", order.Trigger.Price)
+	if err != nil {
+		return false, err
+	}
+	if order.Trigger.Rule == SL_SELL_RULE {
+		return price <= trigger, nil
+	}
+	return price >= trigger, nil
+}
+
+// match the stop-limit levels whose trigger is already reached at the current price against the live book,
+// the others wait for the price and take nothing from it yet
+func checkStopLimitRisk(exchange Exchange, pair string, orders []gateapi.SpotPriceTriggeredOrder, currentPrice float64) (riskReport, error) {
+	var fired []gateapi.Order
+	reached := make([]bool, len(orders))
+	for i, order := range orders {
+		var err error
+		reached[i], err = triggerReached(order, currentPrice)
+		if err != nil {
+			return riskReport{}, err
+		}
+		if reached[i] {
+			fired = append(fired, gateapi.Order{CurrencyPair: order.Market, Side: order.Put.Side, Price: order.Put.Price, Amount: order.Put.Amount})
+		}
+	}
+
+	report, err := checkRisk(exchange, pair, fired)
+	if err != nil {
+		return riskReport{}, err
+	}
+
+	// every level in the ladder order, the fired ones with their estimated fills
+	matched := report.Levels
+	report.Levels = nil
+	for i, order := range orders {
+		if reached[i] {
+			level := matched[0]
+			matched = matched[1:]
+			level.Triggered = true
+			report.Levels = append(report.Levels, level)
+			continue
+		}
+		price, err := parseFloatField("order price", order.Put.Price)
+		if err != nil {
+			return riskReport{}, err
+		}
+		amount, err := parseFloatField("order amount", order.Put.Amount)
+		if err != nil {
+			return riskReport{}, err
+		}
+		report.Levels = append(report.Levels, levelRisk{Price: price, Amount: amount})
+	}
+	return report, nil
+}
+
+func printRiskReport(report riskReport, side string, rules pairRules) {
+	base, quote, _ := splitPair(pair)
+
+	fmt.Fprintf(humanOutput, "\nOrder book: best bid %s %s, best ask %s %s\n", formatAmount(report.BestBid, rules.PricePrecision), quote, formatAmount(report.BestAsk, rules.PricePrecision), quote)
+
+	if triggered := report.triggered(); len(triggered) > 0 {
+		var prices []string
+		for _, level := range triggered {
+			prices = append(prices, formatAmount(level.Price, rules.PricePrecision))
+		}
+		fmt.Fprintf(humanOutput, "%d/%d stop-limit levels are already triggered and are sent to the book at once: %s\n", len(triggered), len(report.Levels), strings.Join(prices, ", "))
+	}
+
+	crossing := report.crossing()
+	if len(crossing) == 0 {
+		fmt.Fprintf(humanOutput, "No level crosses the spread\n")
+		return
+	}
+
+	var prices []string
+	for _, level := range crossing {
+		prices = append(prices, formatAmount(level.Price, rules.PricePrecision))
+	}
+	fmt.Fprintf(humanOutput, "%d/%d levels cross the spread: %s\n", len(crossing), len(report.Levels), strings.Join(prices, ", "))
+
+	avgPrice, slippage := report.slippage(side)
+	fmt.Fprintf(humanOutput, "Immediate fill estimate: %s %s for %.4f %s, avg price %s %s, slippage %.2f %%\n", formatAmount(report.FillAmount, rules.AmountPrecision), base, report.FillQuote, quote, strconv.FormatFloat(avgPrice, 'f', int(rules.PricePrecision)+2, 64), quote, slippage)
+}
+
+// refuse a ladder taking more than the maximum taker exposure from the book
+func checkTakerExposure(report riskReport) error {
+	if maxTakerExposure > 0 && report.FillQuote > maxTakerExposure {
+		_, quote, _ := splitPair(pair)
+		return validationErrorf("the ladder would take %.4f %s from the book immediately, above the maximum taker exposure of %.4f %s (-max-taker)", report.FillQuote, quote, maxTakerExposure, quote)
+	}
+	return nil
+}
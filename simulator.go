@@ -0,0 +1,639 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const (
+	SIM_FEE_RATE        float64 = 0.002
+	SIM_START_TIME_MS   int64   = 1700000000000
+	SIM_DEFAULT_BALANCE float64 = 10000
+
+	// synthetic depth around the last price, one tick apart
+	SIM_BOOK_LEVELS       int32   = 20
+	SIM_BOOK_LEVEL_AMOUNT float64 = 1000
+)
+
+// simulator is a deterministic in-memory exchange.
+// Orders crossing the last price are filled entirely at the last price (taker),
+// resting orders are filled at their own price (maker) when SetPrice moves through them.
+type simulator struct {
+	pairs     map[string]gateapi.CurrencyPair
+	prices    map[string]float64
+	balances  map[string]*simBalance
+	orders    []*gateapi.Order
+	triggered []*gateapi.SpotPriceTriggeredOrder
+	trades    []gateapi.Trade
+	feeRate   float64
+	nextId    int64
+	clockMs   int64
+
+	// prices applied one by one by Advance
+	path     []float64
+	pathPair string
+}
+
+type simBalance struct {
+	available float64
+	locked    float64
+}
+
+func newSimulator() *simulator {
+	return &simulator{
+		pairs:    map[string]gateapi.CurrencyPair{},
+		prices:   map[string]float64{},
+		balances: map[string]*simBalance{},
+		feeRate:  SIM_FEE_RATE,
+		nextId:   1,
+		clockMs:  SIM_START_TIME_MS,
+	}
+}
+
+// build a simulator for the pair given on the command line
+func newSimulatorFromFlags() *simulator {
+	sim := newSimulator()
+
+	price := simPrice
+	if price <= 0 {
+		price = priceMax
+		if side == sell {
+			price = priceMin
+		}
+	}
+	if price <= 0 {
+		price = 1.0
+	}
+	sim.AddPair(pair, price)
+
+	if simPath != "" {
+		for _, field := range strings.Split(simPath, ",") {
+			step, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+			if err != nil || step <= 0 {
+				fmt.Fprintf(os.Stderr, "simpath must be a comma separated list of prices\n")
+				os.Exit(1)
+			}
+			sim.path = append(sim.path, step)
+		}
+		sim.pathPair = pair
+	}
+
+	if simBalances == "" {
+		sim.Deposit(baseCurrency, SIM_DEFAULT_BALANCE)
+		sim.Deposit(quoteCurrency, SIM_DEFAULT_BALANCE)
+		return sim
+	}
+
+	for _, entry := range strings.Split(simBalances, ",") {
+		currency, value, found := strings.Cut(entry, "=")
+		amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !found || err != nil {
+			fmt.Fprintf(os.Stderr, "simbalance must be formatted as CURRENCY=AMOUNT,...\n")
+			os.Exit(1)
+		}
+		sim.Deposit(strings.TrimSpace(currency), amount)
+	}
+	return sim
+}
+
+// AddPair registers a pair with Gate.io like defaults and its starting price
+func (s *simulator) AddPair(pair string, price float64) {
+	base, quote, _ := splitPair(pair)
+	s.pairs[pair] = gateapi.CurrencyPair{
+		Id:              pair,
+		Base:            base,
+		Quote:           quote,
+		Fee:             strconv.FormatFloat(s.feeRate*100, 'f', -1, 64),
+		MinQuoteAmount:  "1",
+		AmountPrecision: 2,
+		Precision:       4,
+		TradeStatus:     "tradable",
+	}
+	s.prices[pair] = price
+}
+
+func (s *simulator) Deposit(currency string, amount float64) {
+	s.balance(currency).available += amount
+}
+
+// SetPrice moves the last price of the pair, filling resting orders and firing triggered orders it crosses
+func (s *simulator) SetPrice(pair string, price float64) {
+	s.prices[pair] = price
+	s.tick()
+
+	for _, order := range s.orders {
+		if order.CurrencyPair != pair || order.Status != "open" {
+			continue
+		}
+		limit := parseSimFloat(order.Price)
+		if (order.Side == buy && limit >= price) || (order.Side == sell && limit <= price) {
+			s.fill(order, limit, "maker")
+		}
+	}
+
+	for _, triggered := range s.triggered {
+		if triggered.Market != pair || triggered.Status != "open" {
+			continue
+		}
+		trigger := parseSimFloat(triggered.Trigger.Price)
+		if (triggered.Trigger.Rule == SL_BUY_RULE && price >= trigger) || (triggered.Trigger.Rule == SL_SELL_RULE && price <= trigger) {
+			fired, err := s.CreateOrder(gateapi.Order{
+				CurrencyPair: pair,
+				Type:         triggered.Put.Type,
+				Side:         triggered.Put.Side,
+				Price:        triggered.Put.Price,
+				Amount:       triggered.Put.Amount,
+				TimeInForce:  triggered.Put.TimeInForce,
+			})
+			triggered.Status = "finished"
+			triggered.Ftime = s.clockMs / 1000
+			if err != nil {
+				triggered.Reason = err.Error()
+				continue
+			}
+			triggered.FiredOrderId, _ = strconv.ParseInt(fired.Id, 10, 64)
+		}
+	}
+}
+
+// Advance moves the price to the next step of the path, it returns false once the path is exhausted
+func (s *simulator) Advance() bool {
+	if len(s.path) == 0 {
+		return false
+	}
+	s.SetPrice(s.pathPair, s.path[0])
+	s.path = s.path[1:]
+	return true
+}
+
+func (s *simulator) GetAccountDetail() (gateapi.AccountDetail, error) {
+	return gateapi.AccountDetail{UserId: 1}, nil
+}
+
+func (s *simulator) GetCurrencyPair(pair string) (gateapi.CurrencyPair, error) {
+	result, ok := s.pairs[pair]
+	if !ok {
+		return gateapi.CurrencyPair{}, simError("INVALID_CURRENCY_PAIR", "unknown currency pair "+pair)
+	}
+	return result, nil
+}
+
+func (s *simulator) ListTickers(pair string) ([]gateapi.Ticker, error) {
+	price, ok := s.prices[pair]
+	if !ok {
+		return nil, simError("INVALID_CURRENCY_PAIR", "unknown currency pair "+pair)
+	}
+	last := strconv.FormatFloat(price, 'f', -1, 64)
+	return []gateapi.Ticker{{CurrencyPair: pair, Last: last, LowestAsk: last, HighestBid: last}}, nil
+}
+
+// the book is synthetic: the same amount on every tick around the last price
+func (s *simulator) ListOrderBook(pair string, options *gateapi.ListOrderBookOpts) (gateapi.OrderBook, error) {
+	price, ok := s.prices[pair]
+	if !ok {
+		return gateapi.OrderBook{}, simError("INVALID_CURRENCY_PAIR", "unknown currency pair "+pair)
+	}
+
+	levels := SIM_BOOK_LEVELS
+	if options != nil && options.Limit.IsSet() && options.Limit.Value() < levels {
+		levels = options.Limit.Value()
+	}
+
+	precision := int(s.pairs[pair].Precision)
+	tick := 1.0
+	for i := 0; i < precision; i++ {
+		tick /= 10
+	}
+	amount := strconv.FormatFloat(SIM_BOOK_LEVEL_AMOUNT, 'f', -1, 64)
+
+	book := gateapi.OrderBook{Current: s.clockMs, Update: s.clockMs}
+	for i := int32(1); i <= levels; i++ {
+		book.Asks = append(book.Asks, []string{strconv.FormatFloat(price+float64(i)*tick, 'f', precision, 64), amount})
+		if bid := price - float64(i)*tick; bid > 0 {
+			book.Bids = append(book.Bids, []string{strconv.FormatFloat(bid, 'f', precision, 64), amount})
+		}
+	}
+	return book, nil
+}
+
+func (s *simulator) ListSpotAccounts() ([]gateapi.SpotAccount, error) {
+	var currencies []string
+	for currency := range s.balances {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	var result []gateapi.SpotAccount
+	for _, currency := range currencies {
+		balance := s.balances[currency]
+		result = append(result, gateapi.SpotAccount{
+			Currency:  currency,
+			Available: strconv.FormatFloat(balance.available, 'f', -1, 64),
+			Locked:    strconv.FormatFloat(balance.locked, 'f', -1, 64),
+		})
+	}
+	return result, nil
+}
+
+func (s *simulator) CreateOrder(order gateapi.Order) (gateapi.Order, error) {
+	base, quote, ok := splitPair(order.CurrencyPair)
+	if _, known := s.pairs[order.CurrencyPair]; !ok || !known {
+		return gateapi.Order{}, simError("INVALID_CURRENCY_PAIR", "unknown currency pair "+order.CurrencyPair)
+	}
+	if order.Side != buy && order.Side != sell {
+		return gateapi.Order{}, simError("INVALID_PARAM_VALUE", "invalid side "+order.Side)
+	}
+
+	price := parseSimFloat(order.Price)
+	amount := parseSimFloat(order.Amount)
+	if price <= 0 || amount <= 0 {
+		return gateapi.Order{}, simError("INVALID_PARAM_VALUE", "price and amount must be positive")
+	}
+
+	lockCurrency, lockAmount := quote, price*amount
+	if order.Side == sell {
+		lockCurrency, lockAmount = base, amount
+	}
+	balance := s.balance(lockCurrency)
+	if balance.available < lockAmount {
+		return gateapi.Order{}, simError("BALANCE_NOT_ENOUGH", "not enough "+lockCurrency)
+	}
+	balance.available -= lockAmount
+	balance.locked += lockAmount
+
+	s.tick()
+	created := order
+	created.Id = strconv.FormatInt(s.nextId, 10)
+	s.nextId++
+	created.CreateTimeMs = s.clockMs
+	created.UpdateTimeMs = s.clockMs
+	created.CreateTime = strconv.FormatInt(s.clockMs/1000, 10)
+	created.UpdateTime = created.CreateTime
+	created.Status = "open"
+	created.Left = order.Amount
+	created.FilledTotal = "0"
+	if created.Type == "" {
+		created.Type = "limit"
+	}
+	if created.TimeInForce == "" {
+		created.TimeInForce = GOOD_TILL_CANCEL
+	}
+	s.orders = append(s.orders, &created)
+
+	last := s.prices[order.CurrencyPair]
+	if (order.Side == buy && price >= last) || (order.Side == sell && price <= last) {
+		s.fill(&created, last, "taker")
+	} else if created.TimeInForce == IMMEDIATE_OR_CANCEL {
+		s.release(&created)
+		created.Status = "cancelled"
+		created.FinishAs = "ioc"
+	}
+
+	return created, nil
+}
+
+func (s *simulator) CreateBatchOrders(orders []gateapi.Order) ([]gateapi.BatchOrder, error) {
+	if len(orders) > GATE_MAX_SIZE_BATCH {
+		return nil, simError("TOO_MANY_ORDERS", "batch size is limited to "+strconv.Itoa(GATE_MAX_SIZE_BATCH))
+	}
+
+	var result []gateapi.BatchOrder
+	for _, order := range orders {
+		created, err := s.CreateOrder(order)
+		if err != nil {
+			e := err.(gateapi.GateAPIError)
+			result = append(result, gateapi.BatchOrder{Text: order.Text, Succeeded: false, Label: e.Label, Message: e.Message})
+			continue
+		}
+		result = append(result, gateapi.BatchOrder{
+			Text:         created.Text,
+			Succeeded:    true,
+			Id:           created.Id,
+			CreateTime:   created.CreateTime,
+			UpdateTime:   created.UpdateTime,
+			CreateTimeMs: created.CreateTimeMs,
+			UpdateTimeMs: created.UpdateTimeMs,
+			Status:       created.Status,
+			CurrencyPair: created.CurrencyPair,
+			Type:         created.Type,
+			Side:         created.Side,
+			Amount:       created.Amount,
+			Price:        created.Price,
+			TimeInForce:  created.TimeInForce,
+			Left:         created.Left,
+			FillPrice:    created.FillPrice,
+			FilledTotal:  created.FilledTotal,
+			Fee:          created.Fee,
+			FeeCurrency:  created.FeeCurrency,
+			FinishAs:     created.FinishAs,
+		})
+	}
+	return result, nil
+}
+
+func (s *simulator) CreateSpotPriceTriggeredOrder(order gateapi.SpotPriceTriggeredOrder) (gateapi.TriggerOrderResponse, error) {
+	if _, ok := s.pairs[order.Market]; !ok {
+		return gateapi.TriggerOrderResponse{}, simError("INVALID_CURRENCY_PAIR", "unknown currency pair "+order.Market)
+	}
+	if order.Trigger.Rule != SL_BUY_RULE && order.Trigger.Rule != SL_SELL_RULE {
+		return gateapi.TriggerOrderResponse{}, simError("INVALID_PARAM_VALUE", "invalid trigger rule "+order.Trigger.Rule)
+	}
+
+	s.tick()
+	created := order
+	created.Id = s.nextId
+	s.nextId++
+	created.Ctime = s.clockMs / 1000
+	created.Status = "open"
+	s.triggered = append(s.triggered, &created)
+
+	return gateapi.TriggerOrderResponse{Id: created.Id}, nil
+}
+
+func (s *simulator) ListAllOpenOrders(options *gateapi.ListAllOpenOrdersOpts) ([]gateapi.OpenOrders, error) {
+	byPair := map[string]*gateapi.OpenOrders{}
+	var pairs []string
+	for _, order := range s.orders {
+		if order.Status != "open" {
+			continue
+		}
+		if _, ok := byPair[order.CurrencyPair]; !ok {
+			byPair[order.CurrencyPair] = &gateapi.OpenOrders{CurrencyPair: order.CurrencyPair}
+			pairs = append(pairs, order.CurrencyPair)
+		}
+		byPair[order.CurrencyPair].Orders = append(byPair[order.CurrencyPair].Orders, *order)
+		byPair[order.CurrencyPair].Total++
+	}
+	sort.Strings(pairs)
+
+	var result []gateapi.OpenOrders
+	for _, pair := range pairs {
+		result = append(result, *byPair[pair])
+	}
+
+	page, limit := int32(1), int32(100)
+	if options != nil && options.Page.IsSet() {
+		page = options.Page.Value()
+	}
+	if options != nil && options.Limit.IsSet() {
+		limit = options.Limit.Value()
+	}
+	return simPage(result, page, limit), nil
+}
+
+func (s *simulator) ListOrders(pair string, status string, options *gateapi.ListOrdersOpts) ([]gateapi.Order, error) {
+	if status != "open" && status != "finished" {
+		return nil, simError("INVALID_PARAM_VALUE", "invalid status "+status)
+	}
+
+	var result []gateapi.Order
+	// most recent first, as returned by Gate.io
+	for i := len(s.orders) - 1; i >= 0; i-- {
+		order := s.orders[i]
+		if order.CurrencyPair != pair || (order.Status == "open") != (status == "open") {
+			continue
+		}
+		if options != nil {
+			if options.Side.IsSet() && options.Side.Value() != "" && options.Side.Value() != order.Side {
+				continue
+			}
+			if options.From.IsSet() && order.CreateTimeMs/1000 < options.From.Value() {
+				continue
+			}
+			if options.To.IsSet() && order.CreateTimeMs/1000 > options.To.Value() {
+				continue
+			}
+		}
+		result = append(result, *order)
+	}
+
+	page, limit := int32(1), int32(100)
+	if options != nil && options.Page.IsSet() {
+		page = options.Page.Value()
+	}
+	if options != nil && options.Limit.IsSet() {
+		limit = options.Limit.Value()
+	}
+	return simPage(result, page, limit), nil
+}
+
+func (s *simulator) ListSpotPriceTriggeredOrders(status string, options *gateapi.ListSpotPriceTriggeredOrdersOpts) ([]gateapi.SpotPriceTriggeredOrder, error) {
+	if status != "open" && status != "finished" {
+		return nil, simError("INVALID_PARAM_VALUE", "invalid status "+status)
+	}
+
+	var result []gateapi.SpotPriceTriggeredOrder
+	for i := len(s.triggered) - 1; i >= 0; i-- {
+		order := s.triggered[i]
+		if (order.Status == "open") != (status == "open") {
+			continue
+		}
+		if options != nil && options.Market.IsSet() && options.Market.Value() != order.Market {
+			continue
+		}
+		result = append(result, *order)
+	}
+
+	offset, limit := int32(0), int32(100)
+	if options != nil && options.Offset.IsSet() {
+		offset = options.Offset.Value()
+	}
+	if options != nil && options.Limit.IsSet() {
+		limit = options.Limit.Value()
+	}
+	if int(offset) > len(result) {
+		offset = int32(len(result))
+	}
+	return simPage(result[offset:], 1, limit), nil
+}
+
+func (s *simulator) ListMyTrades(pair string, options *gateapi.ListMyTradesOpts) ([]gateapi.Trade, error) {
+	var result []gateapi.Trade
+	// most recent first, as returned by Gate.io
+	for i := len(s.trades) - 1; i >= 0; i-- {
+		trade := s.trades[i]
+		if trade.CurrencyPair != pair {
+			continue
+		}
+		created, _ := strconv.ParseInt(trade.CreateTime, 10, 64)
+		if options != nil {
+			if options.From.IsSet() && created < options.From.Value() {
+				continue
+			}
+			if options.To.IsSet() && created > options.To.Value() {
+				continue
+			}
+		}
+		result = append(result, trade)
+	}
+
+	page, limit := int32(1), int32(100)
+	if options != nil && options.Page.IsSet() {
+		page = options.Page.Value()
+	}
+	if options != nil && options.Limit.IsSet() {
+		limit = options.Limit.Value()
+	}
+	return simPage(result, page, limit), nil
+}
+
+func (s *simulator) GetOrder(pair string, orderId string) (gateapi.Order, error) {
+	for _, order := range s.orders {
+		if order.Id == orderId && order.CurrencyPair == pair {
+			return *order, nil
+		}
+	}
+	return gateapi.Order{}, simError("ORDER_NOT_FOUND", "order "+orderId+" not found")
+}
+
+func (s *simulator) CancelOrder(pair string, orderId string) (gateapi.Order, error) {
+	for _, order := range s.orders {
+		if order.Id != orderId || order.CurrencyPair != pair {
+			continue
+		}
+		if order.Status != "open" {
+			return gateapi.Order{}, simError("ORDER_CLOSED", "order "+orderId+" is already closed")
+		}
+		s.tick()
+		s.release(order)
+		order.Status = "cancelled"
+		order.FinishAs = "cancelled"
+		order.UpdateTimeMs = s.clockMs
+		return *order, nil
+	}
+	return gateapi.Order{}, simError("ORDER_NOT_FOUND", "order "+orderId+" not found")
+}
+
+func (s *simulator) CancelBatchOrders(orders []gateapi.CancelBatchOrder) ([]gateapi.CancelOrderResult, error) {
+	var result []gateapi.CancelOrderResult
+	for _, order := range orders {
+		_, err := s.CancelOrder(order.CurrencyPair, order.Id)
+		if err != nil {
+			e := err.(gateapi.GateAPIError)
+			result = append(result, gateapi.CancelOrderResult{CurrencyPair: order.CurrencyPair, Id: order.Id, Label: e.Label, Message: e.Message})
+			continue
+		}
+		result = append(result, gateapi.CancelOrderResult{CurrencyPair: order.CurrencyPair, Id: order.Id, Succeeded: true})
+	}
+	return result, nil
+}
+
+func (s *simulator) CancelSpotPriceTriggeredOrder(orderId string) (gateapi.SpotPriceTriggeredOrder, error) {
+	for _, order := range s.triggered {
+		if strconv.FormatInt(order.Id, 10) != orderId {
+			continue
+		}
+		if order.Status != "open" {
+			return gateapi.SpotPriceTriggeredOrder{}, simError("ORDER_CLOSED", "triggered order "+orderId+" is already closed")
+		}
+		s.tick()
+		order.Status = "cancelled"
+		order.Ftime = s.clockMs / 1000
+		return *order, nil
+	}
+	return gateapi.SpotPriceTriggeredOrder{}, simError("ORDER_NOT_FOUND", "triggered order "+orderId+" not found")
+}
+
+// fill the whole order at the given price and settle balances
+func (s *simulator) fill(order *gateapi.Order, price float64, role string) {
+	base, quote, _ := splitPair(order.CurrencyPair)
+	amount := parseSimFloat(order.Amount)
+	limit := parseSimFloat(order.Price)
+	total := price * amount
+
+	if order.Side == buy {
+		s.balance(quote).locked -= limit * amount
+		// refund the price improvement
+		s.balance(quote).available += (limit - price) * amount
+		fee := amount * s.feeRate
+		s.balance(base).available += amount - fee
+		order.Fee = strconv.FormatFloat(fee, 'f', -1, 64)
+		order.FeeCurrency = base
+	} else {
+		s.balance(base).locked -= amount
+		fee := total * s.feeRate
+		s.balance(quote).available += total - fee
+		order.Fee = strconv.FormatFloat(fee, 'f', -1, 64)
+		order.FeeCurrency = quote
+	}
+
+	order.Status = "closed"
+	order.FinishAs = "filled"
+	order.Left = "0"
+	order.FilledTotal = strconv.FormatFloat(total, 'f', -1, 64)
+	order.FillPrice = order.FilledTotal
+	order.AvgDealPrice = strconv.FormatFloat(price, 'f', -1, 64)
+	order.UpdateTimeMs = s.clockMs
+	order.UpdateTime = strconv.FormatInt(s.clockMs/1000, 10)
+
+	s.trades = append(s.trades, gateapi.Trade{
+		Id:           strconv.Itoa(len(s.trades) + 1),
+		CreateTime:   order.UpdateTime,
+		CreateTimeMs: strconv.FormatInt(s.clockMs, 10),
+		CurrencyPair: order.CurrencyPair,
+		Side:         order.Side,
+		Role:         role,
+		Amount:       order.Amount,
+		Price:        order.AvgDealPrice,
+		OrderId:      order.Id,
+		Fee:          order.Fee,
+		FeeCurrency:  order.FeeCurrency,
+		Text:         order.Text,
+	})
+}
+
+// unlock the funds reserved by an unfilled order
+func (s *simulator) release(order *gateapi.Order) {
+	base, quote, _ := splitPair(order.CurrencyPair)
+	amount := parseSimFloat(order.Left)
+	if order.Side == buy {
+		locked := parseSimFloat(order.Price) * amount
+		s.balance(quote).locked -= locked
+		s.balance(quote).available += locked
+	} else {
+		s.balance(base).locked -= amount
+		s.balance(base).available += amount
+	}
+}
+
+func (s *simulator) balance(currency string) *simBalance {
+	currency = strings.ToUpper(currency)
+	if _, ok := s.balances[currency]; !ok {
+		s.balances[currency] = &simBalance{}
+	}
+	return s.balances[currency]
+}
+
+// advance the simulated clock, one second per event
+func (s *simulator) tick() {
+	s.clockMs += 1000
+}
+
+func simError(label string, message string) gateapi.GateAPIError {
+	return gateapi.GateAPIError{Label: label, Message: message}
+}
+
+func simPage[T any](items []T, page int32, limit int32) []T {
+	if page < 1 {
+		page = 1
+	}
+	start := int((page - 1) * limit)
+	if start >= len(items) {
+		return []T{}
+	}
+	end := start + int(limit)
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+func parseSimFloat(value string) float64 {
+	result, _ := strconv.ParseFloat(value, 64)
+	return result
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gateio/gateapi-go/v6"
+)
+
+const SIM_TEST_PAIR = "ALPH_USDT"
+
+func simTestOrder(side string, price string, amount string, timeInForce string) gateapi.Order {
+	return gateapi.Order{CurrencyPair: SIM_TEST_PAIR, Side: side, Price: price, Amount: amount, TimeInForce: timeInForce}
+}
+
+func TestSimulatorOrders(t *testing.T) {
+	type result struct {
+		status   string
+		finishAs string
+		label    string
+	}
+	tests := []struct {
+		name     string
+		deposits map[string]float64
+		orders   []gateapi.Order
+		// price set after the orders are placed, 0 keeps the starting price of 0.40
+		moveTo    float64
+		want      []result
+		available map[string]float64
+		locked    map[string]float64
+	}{
+		{
+			name:      "buy crossing the price is filled at the last price",
+			deposits:  map[string]float64{"USDT": 100},
+			orders:    []gateapi.Order{simTestOrder(buy, "0.42", "100", GOOD_TILL_CANCEL)},
+			want:      []result{{"closed", "filled", ""}},
+			available: map[string]float64{"USDT": 60, "ALPH": 99.8},
+			locked:    map[string]float64{"USDT": 0},
+		},
+		{
+			name:      "sell crossing the price is filled at the last price",
+			deposits:  map[string]float64{"ALPH": 100},
+			orders:    []gateapi.Order{simTestOrder(sell, "0.38", "100", GOOD_TILL_CANCEL)},
+			want:      []result{{"closed", "filled", ""}},
+			available: map[string]float64{"USDT": 39.92, "ALPH": 0},
+			locked:    map[string]float64{"ALPH": 0},
+		},
+		{
+			name:      "resting buy locks its quote",
+			deposits:  map[string]float64{"USDT": 100},
+			orders:    []gateapi.Order{simTestOrder(buy, "0.38", "100", GOOD_TILL_CANCEL)},
+			want:      []result{{"open", "", ""}},
+			available: map[string]float64{"USDT": 62, "ALPH": 0},
+			locked:    map[string]float64{"USDT": 38},
+		},
+		{
+			name:      "resting sell locks its base",
+			deposits:  map[string]float64{"ALPH": 100},
+			orders:    []gateapi.Order{simTestOrder(sell, "0.42", "60", GOOD_TILL_CANCEL)},
+			want:      []result{{"open", "", ""}},
+			available: map[string]float64{"ALPH": 40},
+			locked:    map[string]float64{"ALPH": 60},
+		},
+		{
+			name:      "resting buy is filled at its own price when the price moves through it",
+			deposits:  map[string]float64{"USDT": 100},
+			orders:    []gateapi.Order{simTestOrder(buy, "0.38", "100", GOOD_TILL_CANCEL)},
+			moveTo:    0.37,
+			want:      []result{{"closed", "filled", ""}},
+			available: map[string]float64{"USDT": 62, "ALPH": 99.8},
+			locked:    map[string]float64{"USDT": 0},
+		},
+		{
+			name:      "resting sell stays open while the price does not reach it",
+			deposits:  map[string]float64{"ALPH": 100},
+			orders:    []gateapi.Order{simTestOrder(sell, "0.42", "100", GOOD_TILL_CANCEL)},
+			moveTo:    0.41,
+			want:      []result{{"open", "", ""}},
+			available: map[string]float64{"ALPH": 0, "USDT": 0},
+			locked:    map[string]float64{"ALPH": 100},
+		},
+		{
+			name:      "unfilled ioc is cancelled and releases its funds",
+			deposits:  map[string]float64{"USDT": 100},
+			orders:    []gateapi.Order{simTestOrder(buy, "0.38", "100", IMMEDIATE_OR_CANCEL)},
+			want:      []result{{"cancelled", "ioc", ""}},
+			available: map[string]float64{"USDT": 100, "ALPH": 0},
+			locked:    map[string]float64{"USDT": 0},
+		},
+		{
+			name:      "crossing ioc is filled",
+			deposits:  map[string]float64{"ALPH": 100},
+			orders:    []gateapi.Order{simTestOrder(sell, "0.40", "100", IMMEDIATE_OR_CANCEL)},
+			want:      []result{{"closed", "filled", ""}},
+			available: map[string]float64{"USDT": 39.92, "ALPH": 0},
+			locked:    map[string]float64{"ALPH": 0},
+		},
+		{
+			name:     "batch rejects only the orders the balance cannot cover",
+			deposits: map[string]float64{"USDT": 100},
+			orders: []gateapi.Order{
+				simTestOrder(buy, "0.38", "100", GOOD_TILL_CANCEL),
+				simTestOrder(buy, "0.36", "200", GOOD_TILL_CANCEL),
+				simTestOrder(buy, "0.36", "50", GOOD_TILL_CANCEL),
+			},
+			want:      []result{{"open", "", ""}, {"", "", "BALANCE_NOT_ENOUGH"}, {"open", "", ""}},
+			available: map[string]float64{"USDT": 44},
+			locked:    map[string]float64{"USDT": 56},
+		},
+		{
+			name:     "batch rejects invalid orders",
+			deposits: map[string]float64{"USDT": 100},
+			orders: []gateapi.Order{
+				simTestOrder(buy, "0", "100", GOOD_TILL_CANCEL),
+				simTestOrder("hold", "0.38", "100", GOOD_TILL_CANCEL),
+				{CurrencyPair: "DOGE_USDT", Side: buy, Price: "0.1", Amount: "100"},
+			},
+			want:      []result{{"", "", "INVALID_PARAM_VALUE"}, {"", "", "INVALID_PARAM_VALUE"}, {"", "", "INVALID_CURRENCY_PAIR"}},
+			available: map[string]float64{"USDT": 100},
+			locked:    map[string]float64{"USDT": 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sim := newSimulator()
+			sim.AddPair(SIM_TEST_PAIR, 0.40)
+			for currency, amount := range test.deposits {
+				sim.Deposit(currency, amount)
+			}
+
+			results, err := sim.CreateBatchOrders(test.orders)
+			if err != nil {
+				t.Fatalf("batch refused: %s", err)
+			}
+			if len(results) != len(test.want) {
+				t.Fatalf("%d results, want %d", len(results), len(test.want))
+			}
+			if test.moveTo > 0 {
+				sim.SetPrice(SIM_TEST_PAIR, test.moveTo)
+			}
+
+			for i, want := range test.want {
+				if want.label != "" {
+					if results[i].Succeeded || results[i].Label != want.label {
+						t.Errorf("order %d: succeeded %t with label %q, want rejected with %s", i, results[i].Succeeded, results[i].Label, want.label)
+					}
+					continue
+				}
+				if !results[i].Succeeded {
+					t.Fatalf("order %d rejected: %s %s", i, results[i].Label, results[i].Message)
+				}
+				order, err := sim.GetOrder(SIM_TEST_PAIR, results[i].Id)
+				if err != nil {
+					t.Fatalf("order %d: %s", i, err)
+				}
+				if order.Status != want.status || order.FinishAs != want.finishAs {
+					t.Errorf("order %d is %s finished as %q, want %s finished as %q", i, order.Status, order.FinishAs, want.status, want.finishAs)
+				}
+			}
+
+			for currency, want := range test.available {
+				if got := sim.balance(currency).available; math.Abs(got-want) > 1e-9 {
+					t.Errorf("available %s = %v, want %v", currency, got, want)
+				}
+			}
+			for currency, want := range test.locked {
+				if got := sim.balance(currency).locked; math.Abs(got-want) > 1e-9 {
+					t.Errorf("locked %s = %v, want %v", currency, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSimulatorBatchTooLarge(t *testing.T) {
+	sim := newSimulator()
+	sim.AddPair(SIM_TEST_PAIR, 0.40)
+	sim.Deposit("USDT", 1000)
+
+	orders := make([]gateapi.Order, GATE_MAX_SIZE_BATCH+1)
+	for i := range orders {
+		orders[i] = simTestOrder(buy, "0.38", "1", GOOD_TILL_CANCEL)
+	}
+	if _, err := sim.CreateBatchOrders(orders); err == nil {
+		t.Fatalf("a batch of %d orders was accepted", len(orders))
+	}
+	if open, _ := sim.ListOrders(SIM_TEST_PAIR, "open", nil); len(open) != 0 {
+		t.Fatalf("%d orders placed by a refused batch", len(open))
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+)
+
+// distance between two levels of a ladder
+//
+// With Percent the prices grow geometrically, each level is Percent % above the previous one.
+// With Orders the distance is derived from the price range so the ladder has exactly Orders levels,
+// arithmetic or geometric depending on Geometric.
+type spacing struct {
+	Step      float64 `json:"step,omitempty"`
+	Percent   float64 `json:"percent,omitempty"`
+	Orders    int     `json:"orders,omitempty"`
+	Geometric bool    `json:"geometric,omitempty"`
+}
+
+func (s spacing) isGeometric() bool {
+	return s.Geometric || s.Percent > 0
+}
+
+// number of intervals between priceMin and priceMax, one order is placed per interval
+func (s spacing) intervals(priceMin float64, priceMax float64) float64 {
+	if s.Orders > 0 {
+		return float64(s.Orders)
+	}
+	if s.Percent > 0 {
+		return math.Log(priceMax/priceMin) / math.Log(1+s.Percent/100)
+	}
+	return math.Abs(priceMax-priceMin) / s.Step
+}
+
+// price of the level following price
+func (s spacing) next(price float64, priceMin float64, priceMax float64) float64 {
+	if s.Orders > 0 {
+		if s.isGeometric() {
+			return price * math.Pow(priceMax/priceMin, 1/float64(s.Orders))
+		}
+		return price + (priceMax-priceMin)/float64(s.Orders)
+	}
+	if s.Percent > 0 {
+		return price * (1 + s.Percent/100)
+	}
+	return price + s.Step
+}
+
+// price of the level preceding price
+func (s spacing) previous(price float64, priceMin float64, priceMax float64) float64 {
+	if s.Orders > 0 {
+		if s.isGeometric() {
+			return price / math.Pow(priceMax/priceMin, 1/float64(s.Orders))
+		}
+		return price - (priceMax-priceMin)/float64(s.Orders)
+	}
+	if s.Percent > 0 {
+		return price / (1 + s.Percent/100)
+	}
+	return price - s.Step
+}
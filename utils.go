@@ -1,10 +1,8 @@
 package main
 
 import (
-	"context"
 	"fmt"
 	"math"
-	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -17,27 +15,40 @@ const (
 	sell string = "sell"
 )
 
-// from the ticker choose if it's crypto or fiat
-func selectFiatOrCrypto(ticker string, pair string, side string, priceMin float64, priceMax float64, amount float64, steps float64, timeInForce string) []gateapi.Order {
+// a ladder without order is refused rather than sent empty
+func errNoOrder(priceMin float64, priceMax float64) error {
+	return validationErrorf("no order produced between %s and %s, check the range, the spacing and the amount", strconv.FormatFloat(priceMin, 'f', -1, 64), strconv.FormatFloat(priceMax, 'f', -1, 64))
+}
 
-	if strings.ToUpper(ticker) == "USDT" {
-		return createOrder("ALPH_USDT", side, priceMin, priceMax, amountUSDT, steps, timeInForce)
+// currency and amount of the ladder given on the command line
+func ladderAmount() (string, float64) {
+	if amountBase > 0.0 {
+		return baseCurrency, amountBase
 	}
-
-	return createOrderAlph("ALPH_USDT", side, priceMin, priceMax, amountAlph, steps, timeInForce)
+	return quoteCurrency, amountQuote
 }
 
-func selectFiatOrCryptoTriggered(ticker string, pair string, side string, priceMin float64, priceMax float64, amount float64, steps float64) []gateapi.SpotPriceTriggeredOrder {
-
-	if strings.ToUpper(ticker) == "USDT" {
-		return createTriggeredOrder("ALPH_USDT", side, priceMin, priceMax, amountUSDT, steps)
+// currency spent by the orders of a side, the base of a sell and the quote of a buy
+func spentCurrency(pair string, side string) string {
+	base, quote, _ := splitPair(pair)
+	if side == sell {
+		return base
 	}
+	return quote
+}
 
-	return []gateapi.SpotPriceTriggeredOrder{}
+// split a BASE_QUOTE pair into its currencies
+func splitPair(pair string) (string, string, bool) {
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(pair)), "_")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
 }
 
-func checkOrdersOpen(client *gateapi.APIClient, ctx *context.Context, pair string) bool {
-	return len(getOpenOrders(client, ctx, pair)) > 0
+func checkOrdersOpen(exchange Exchange, pair string) (bool, error) {
+	orders, err := getOpenOrders(exchange, pair)
+	return len(orders) > 0, err
 }
 
 func generateId(length int) string {
@@ -54,24 +65,52 @@ func round(x, unit float64) float64 {
 	return math.Round(x*unit) / unit
 }
 
-func formatOpenOrders(order *gateapi.Order) {
+// round a price to the pair price precision
+func quantizePrice(price float64, rules pairRules) float64 {
+	return round(price, math.Pow10(int(rules.PricePrecision)))
+}
+
+// round an amount down to the pair amount precision so it never exceeds the budget
+func quantizeAmount(amount float64, rules pairRules) float64 {
+	unit := math.Pow10(int(rules.AmountPrecision))
+	// absorb float noise such as 2.9999999999 before flooring
+	return math.Floor(amount*unit+1e-9) / unit
+}
+
+func formatAmount(x float64, precision int32) string {
+	return strconv.FormatFloat(x, 'f', int(precision), 64)
+}
+
+// refuse an order below the exchange minimums of the pair
+func checkPairMinimums(rules pairRules, pair string, price float64, baseAmount float64) error {
+	base, quote, _ := splitPair(pair)
+
+	if baseAmount <= 0 || baseAmount < rules.MinBaseAmount {
+		return validationErrorf("order at %s %s is %s %s, minimum is %s %s. Increase the amount or reduce the number of orders", formatAmount(price, rules.PricePrecision), quote, formatAmount(baseAmount, rules.AmountPrecision), base, formatAmount(rules.MinBaseAmount, rules.AmountPrecision), base)
+	}
+
+	if baseAmount*price < rules.MinQuoteAmount {
+		return validationErrorf("order at %s %s is %.4f %s, minimum is %.4f %s. Increase the amount or reduce the number of orders", formatAmount(price, rules.PricePrecision), quote, baseAmount*price, quote, rules.MinQuoteAmount, quote)
+	}
+	return nil
+}
+
+func formatOpenOrders(order *gateapi.Order) error {
+	base, quote, _ := splitPair(order.CurrencyPair)
 
-	amount, err := strconv.ParseFloat(order.Amount, 64)
+	amount, err := parseFloatField("order amount", order.Amount)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Formatting not possible for formatOpenOrders: %s\n", err)
-		panic(err)
+		return err
 	}
 
-	filled, err := strconv.ParseFloat(order.FilledTotal, 64)
+	filled, err := parseFloatField("order filled total", order.FilledTotal)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Formatting not possible for formatOpenOrders: %s\n", err)
-		panic(err)
+		return err
 	}
 
-	price, err := strconv.ParseFloat(order.Price, 64)
+	price, err := parseFloatField("order price", order.Price)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Formatting not possible for formatOpenOrders: %s\n", err)
-		panic(err)
+		return err
 	}
 	amountFiat := price * amount
 
@@ -80,7 +119,8 @@ func formatOpenOrders(order *gateapi.Order) {
 		percentLeft = amount / filled
 	}
 
-	fmt.Printf("Price: %s USDT, Volume: %.3f ALPH | %.3f USDT, Filled Total: %.3f ALPH (%.2f %%)\n", order.Price, amount, amountFiat, filled, percentLeft)
+	fmt.Printf("Price: %s %s, Volume: %.3f %s | %.3f %s, Filled Total: %.3f %s (%.2f %%)\n", order.Price, quote, amount, base, amountFiat, quote, filled, base, percentLeft)
+	return nil
 }
 
 func median(data []float64) float64 {
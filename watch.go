@@ -0,0 +1,363 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const WATCH_COMMAND = "watch"
+
+const GATE_WS_URL = "wss://api.gateio.ws/ws/v4/"
+
+const (
+	WS_ORDERS_CHANNEL     = "spot.orders"
+	WS_USERTRADES_CHANNEL = "spot.usertrades"
+	WS_TICKERS_CHANNEL    = "spot.tickers"
+	WS_PING_CHANNEL       = "spot.ping"
+)
+
+const (
+	WS_PING_INTERVAL     = 10 * time.Second
+	WS_MAX_RECONNECT_GAP = 30 * time.Second
+)
+
+var wsUrl string
+
+// wsConn is a websocket connection exchanging text messages
+type wsConn interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(message []byte) error
+	Close() error
+}
+
+// wsTransport opens websocket connections, a fake transport or -wsurl pointing to a local server can drive the watcher
+type wsTransport interface {
+	Dial(url string) (wsConn, error)
+}
+
+type gorillaTransport struct{}
+
+type gorillaConn struct {
+	conn *websocket.Conn
+}
+
+func (gorillaTransport) Dial(url string) (wsConn, error) {
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return gorillaConn{conn: conn}, nil
+}
+
+func (c gorillaConn) ReadMessage() ([]byte, error) {
+	_, message, err := c.conn.ReadMessage()
+	return message, err
+}
+
+func (c gorillaConn) WriteMessage(message []byte) error {
+	return c.conn.WriteMessage(websocket.TextMessage, message)
+}
+
+func (c gorillaConn) Close() error {
+	return c.conn.Close()
+}
+
+type wsRequest struct {
+	Time    int64    `json:"time"`
+	Channel string   `json:"channel"`
+	Event   string   `json:"event,omitempty"`
+	Payload []string `json:"payload,omitempty"`
+	Auth    *wsAuth  `json:"auth,omitempty"`
+}
+
+type wsAuth struct {
+	Method string `json:"method"`
+	Key    string `json:"KEY"`
+	Sign   string `json:"SIGN"`
+}
+
+type wsMessage struct {
+	Time    int64           `json:"time"`
+	Channel string          `json:"channel"`
+	Event   string          `json:"event"`
+	Error   *wsError        `json:"error"`
+	Result  json.RawMessage `json:"result"`
+}
+
+type wsError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// fields of a spot.usertrades update used by the watcher
+type wsUserTrade struct {
+	OrderId      string `json:"order_id"`
+	CurrencyPair string `json:"currency_pair"`
+	Side         string `json:"side"`
+	Role         string `json:"role"`
+	Amount       string `json:"amount"`
+	Price        string `json:"price"`
+	Fee          string `json:"fee"`
+	FeeCurrency  string `json:"fee_currency"`
+	Text         string `json:"text"`
+}
+
+// fields of a spot.orders update used by the watcher
+type wsOrder struct {
+	Event        string `json:"event"`
+	Id           string `json:"id"`
+	Text         string `json:"text"`
+	CurrencyPair string `json:"currency_pair"`
+	Side         string `json:"side"`
+	Price        string `json:"price"`
+	Amount       string `json:"amount"`
+	Left         string `json:"left"`
+	FinishAs     string `json:"finish_as"`
+}
+
+type wsTicker struct {
+	CurrencyPair string `json:"currency_pair"`
+	Last         string `json:"last"`
+}
+
+// running totals of the fills seen since the watcher started
+type watchTotals struct {
+	fills       int
+	boughtBase  float64
+	boughtQuote float64
+	soldBase    float64
+	soldQuote   float64
+	fees        map[string]float64
+}
+
+type watcher struct {
+	transport wsTransport
+	url       string
+	key       string
+	secret    string
+	pair      string
+	ladder    int
+	lastPrice string
+	totals    watchTotals
+}
+
+func newWatcher(transport wsTransport, url string, key string, secret string, pair string, ladder int) *watcher {
+	return &watcher{
+		transport: transport,
+		url:       url,
+		key:       key,
+		secret:    secret,
+		pair:      pair,
+		ladder:    ladder,
+		totals:    watchTotals{fees: map[string]float64{}},
+	}
+}
+
+// sign a private channel request as described in the Gate.io websocket api
+func (w *watcher) sign(channel string, event string, timestamp int64) string {
+	mac := hmac.New(sha512.New, []byte(w.secret))
+	mac.Write([]byte(fmt.Sprintf("channel=%s&event=%s&time=%d", channel, event, timestamp)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *watcher) subscribe(conn wsConn) error {
+	for _, channel := range []string{WS_TICKERS_CHANNEL, WS_ORDERS_CHANNEL, WS_USERTRADES_CHANNEL} {
+		request := wsRequest{Time: time.Now().Unix(), Channel: channel, Event: "subscribe", Payload: []string{w.pair}}
+		if channel != WS_TICKERS_CHANNEL {
+			request.Auth = &wsAuth{Method: "api_key", Key: w.key, Sign: w.sign(channel, request.Event, request.Time)}
+		}
+
+		content, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		if err := conn.WriteMessage(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run until stop is closed, reconnecting with a growing delay when the connection drops
+func (w *watcher) run(stop <-chan struct{}) {
+	gap := time.Second
+	for {
+		received, err := w.session(stop)
+		if err == nil {
+			return
+		}
+		// a connection that worked starts the backoff again
+		if received {
+			gap = time.Second
+		}
+
+		fmt.Fprintf(os.Stderr, "Websocket error: %s, reconnecting in %s\n", err, gap)
+		select {
+		case <-stop:
+			return
+		case <-time.After(gap):
+		}
+		gap *= 2
+		if gap > WS_MAX_RECONNECT_GAP {
+			gap = WS_MAX_RECONNECT_GAP
+		}
+	}
+}
+
+// one connection, returns nil when stopped and the error otherwise,
+// received tells whether any message came through before it ended
+func (w *watcher) session(stop <-chan struct{}) (received bool, err error) {
+	conn, err := w.transport.Dial(w.url)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if err := w.subscribe(conn); err != nil {
+		return false, err
+	}
+
+	// closed when the session ends so the reader never blocks on a message nobody receives,
+	// deferred after Close so it runs first
+	done := make(chan struct{})
+	defer close(done)
+
+	messages := make(chan []byte)
+	failure := make(chan error, 1)
+	go func() {
+		for {
+			message, err := conn.ReadMessage()
+			if err != nil {
+				failure <- err
+				return
+			}
+			select {
+			case messages <- message:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(WS_PING_INTERVAL)
+	defer ping.Stop()
+	for {
+		select {
+		case <-stop:
+			return received, nil
+		case err := <-failure:
+			return received, err
+		case message := <-messages:
+			received = true
+			w.handle(message)
+		case <-ping.C:
+			content, _ := json.Marshal(wsRequest{Time: time.Now().Unix(), Channel: WS_PING_CHANNEL})
+			if err := conn.WriteMessage(content); err != nil {
+				return received, err
+			}
+		}
+	}
+}
+
+func (w *watcher) handle(content []byte) {
+	var message wsMessage
+	if err := json.Unmarshal(content, &message); err != nil {
+		fmt.Fprintf(os.Stderr, "Unreadable websocket message: %s\n", err)
+		return
+	}
+
+	if message.Error != nil {
+		fmt.Fprintf(os.Stderr, "%s %s error %d: %s\n", message.Channel, message.Event, message.Error.Code, message.Error.Message)
+		return
+	}
+	if message.Event != "update" {
+		return
+	}
+
+	switch message.Channel {
+	case WS_TICKERS_CHANNEL:
+		var ticker wsTicker
+		if err := json.Unmarshal(message.Result, &ticker); err == nil && ticker.Last != w.lastPrice {
+			w.lastPrice = ticker.Last
+			_, quote, _ := splitPair(ticker.CurrencyPair)
+			fmt.Printf("%s price: %s %s\n", time.Unix(message.Time, 0).Format(time.TimeOnly), ticker.Last, quote)
+		}
+	case WS_ORDERS_CHANNEL:
+		var orders []wsOrder
+		if err := json.Unmarshal(message.Result, &orders); err != nil {
+			return
+		}
+		for _, order := range orders {
+			if order.Event == "finish" && w.isOurs(order.Text) {
+				fmt.Printf("Order %s (%s) %s at %s finished as %s\n", order.Id, order.Text, order.Side, order.Price, order.FinishAs)
+			}
+		}
+	case WS_USERTRADES_CHANNEL:
+		var trades []wsUserTrade
+		if err := json.Unmarshal(message.Result, &trades); err != nil {
+			return
+		}
+		for _, trade := range trades {
+			if w.isOurs(trade.Text) {
+				w.onFill(trade)
+			}
+		}
+	}
+}
+
+// orders of the watched ladder, or of any ladder when none is selected
+func (w *watcher) isOurs(text string) bool {
+	if w.ladder > 0 {
+		return ladderIdFromText(text) == w.ladder
+	}
+	return ladderIdFromText(text) > 0
+}
+
+func (w *watcher) onFill(trade wsUserTrade) {
+	base, quote, _ := splitPair(trade.CurrencyPair)
+	amount, _ := strconv.ParseFloat(trade.Amount, 64)
+	price, _ := strconv.ParseFloat(trade.Price, 64)
+	fee, _ := strconv.ParseFloat(trade.Fee, 64)
+
+	w.totals.fills++
+	w.totals.fees[trade.FeeCurrency] += fee
+	if trade.Side == buy {
+		w.totals.boughtBase += amount
+		w.totals.boughtQuote += amount * price
+	} else {
+		w.totals.soldBase += amount
+		w.totals.soldQuote += amount * price
+	}
+
+	fmt.Printf("%s filled (%s): %s %s at %s %s, order %s (%s)\n", trade.Side, trade.Role, trade.Amount, base, trade.Price, quote, trade.OrderId, trade.Text)
+	fmt.Printf("  %d fills, bought %.4f %s for %.4f %s, sold %.4f %s for %.4f %s\n", w.totals.fills, w.totals.boughtBase, base, w.totals.boughtQuote, quote, w.totals.soldBase, base, w.totals.soldQuote, quote)
+}
+
+func runWatch() {
+	stop := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		close(stop)
+	}()
+
+	watcher := newWatcher(gorillaTransport{}, wsUrl, gateioKey, gateioSecret, pair, ladderId)
+	fmt.Printf("Watching %s on %s, stop with Ctrl-C\n", pair, wsUrl)
+	watcher.run(stop)
+
+	fmt.Printf("\n%d fills\n", watcher.totals.fills)
+	for currency, fee := range watcher.totals.fees {
+		fmt.Printf("fees: %.6f %s\n", fee, currency)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+// fakeTransport hands out connections replaying frames, then failing like a dropped socket
+type fakeTransport struct {
+	frames []string
+}
+
+type fakeConn struct {
+	frames []string
+}
+
+func (t *fakeTransport) Dial(url string) (wsConn, error) {
+	return &fakeConn{frames: t.frames}, nil
+}
+
+func (c *fakeConn) ReadMessage() ([]byte, error) {
+	if len(c.frames) == 0 {
+		return nil, errors.New("connection dropped")
+	}
+	frame := c.frames[0]
+	c.frames = c.frames[1:]
+	return []byte(frame), nil
+}
+
+func (c *fakeConn) WriteMessage(message []byte) error {
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func TestWatchRunningTotals(t *testing.T) {
+	transport := &fakeTransport{frames: []string{
+		`{"time":1700000000,"channel":"spot.tickers","event":"update","result":{"currency_pair":"ALPH_USDT","last":"0.40"}}`,
+		`{"time":1700000001,"channel":"spot.usertrades","event":"update","result":[` +
+			`{"order_id":"1","currency_pair":"ALPH_USDT","side":"buy","role":"maker","amount":"10","price":"0.38","fee":"0.02","fee_currency":"ALPH","text":"t-L7-aaaa"},` +
+			`{"order_id":"2","currency_pair":"ALPH_USDT","side":"buy","role":"maker","amount":"5","price":"0.39","fee":"0.01","fee_currency":"ALPH","text":"t-L8-bbbb"}]}`,
+		`{"time":1700000002,"channel":"spot.orders","event":"update","result":[` +
+			`{"event":"finish","id":"1","text":"t-L7-aaaa","currency_pair":"ALPH_USDT","side":"buy","price":"0.38","amount":"10","left":"0","finish_as":"filled"}]}`,
+		`{"time":1700000003,"channel":"spot.usertrades","event":"update","result":[` +
+			`{"order_id":"3","currency_pair":"ALPH_USDT","side":"sell","role":"taker","amount":"4","price":"0.42","fee":"0.003","fee_currency":"USDT","text":"t-L7-cccc"},` +
+			`{"order_id":"4","currency_pair":"ALPH_USDT","side":"sell","role":"taker","amount":"2","price":"0.42","fee":"0.001","fee_currency":"USDT","text":"t-manual"}]}`,
+	}}
+
+	w := newWatcher(transport, "ws://fake", "key", "secret", "ALPH_USDT", 7)
+	received, err := w.session(make(chan struct{}))
+	if err == nil || !received {
+		t.Fatalf("session should end with the dropped connection after receiving messages, got received %v, error %v", received, err)
+	}
+
+	totals := w.totals
+	if totals.fills != 2 {
+		t.Errorf("fills: got %d, want 2", totals.fills)
+	}
+	checks := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"bought base", totals.boughtBase, 10},
+		{"bought quote", totals.boughtQuote, 3.8},
+		{"sold base", totals.soldBase, 4},
+		{"sold quote", totals.soldQuote, 1.68},
+		{"ALPH fees", totals.fees["ALPH"], 0.02},
+		{"USDT fees", totals.fees["USDT"], 0.003},
+	}
+	for _, check := range checks {
+		if math.Abs(check.got-check.want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", check.name, check.got, check.want)
+		}
+	}
+	if w.lastPrice != "0.40" {
+		t.Errorf("last price: got %s, want 0.40", w.lastPrice)
+	}
+}